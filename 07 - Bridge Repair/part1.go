@@ -2,15 +2,31 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func powInt(base int, exponent int) int {
-	return int(math.Pow(float64(base), float64(exponent)))
+var (
+	errOverflow = errors.New("integer overflow")
+	errPastGoal = errors.New("result passed goal")
+)
+
+func powInt(base int, exponent int) (int, bool) {
+	result := 1
+	for range exponent {
+		var ok bool
+		result, ok = op_mul(result, base)
+		if !ok {
+			return 0, false
+		}
+	}
+	return result, true
 }
 
 type operator uint8
@@ -20,14 +36,24 @@ const (
 	mul
 )
 
-func op_add(a int, b int) int {
-	return a + b
+func op_add(a int, b int) (int, bool) {
+	if (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b) {
+		return 0, false
+	}
+	return a + b, true
 }
 
-func op_mul(a int, b int) int {
-	return a * b
-}
+func op_mul(a int, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
 
+	result := a * b
+	if result/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return result, true
+}
 
 func newOperator(bit uint) operator {
 	switch bit {
@@ -52,7 +78,7 @@ func generateOperators(byte uint, num int) []operator {
 	return operators
 }
 
-func (o operator) toFunc() func(int, int) int {
+func (o operator) toFunc() func(int, int) (int, bool) {
 	switch o {
 	case add:
 		return op_add
@@ -63,81 +89,234 @@ func (o operator) toFunc() func(int, int) int {
 	}
 }
 
+func (o operator) toBigFunc() func(*big.Int, *big.Int) *big.Int {
+	switch o {
+	case add:
+		return bigAdd
+	case mul:
+		return bigMul
+	default:
+		panic("invalid operator")
+	}
+}
+
+func splitEquation(line string) (string, []string) {
+	colSplit := strings.Split(line, ":")
+
+	var components []string
+	for _, str := range strings.Split(colSplit[1], " ") {
+		if len(str) == 0 {
+			continue
+		}
+		components = append(components, str)
+	}
+
+	return colSplit[0], components
+}
+
 type equation struct {
 	goal       int
 	components []int
 }
 
-func newEquation(line string) equation {
-	colSplit := strings.Split(line, ":")
-
-	// -- Parse goal number.
-	goal, err := strconv.Atoi(colSplit[0])
+func parseInt(str string) int {
+	num, err := strconv.Atoi(str)
+	if errors.Is(err, strconv.ErrRange) {
+		panic(fmt.Errorf("%w: %s does not fit in int, rerun with -big", errOverflow, str))
+	}
 	if err != nil {
 		panic(err)
 	}
+	return num
+}
+
+func newEquation(line string) equation {
+	goalStr, componentStrs := splitEquation(line)
+
+	// -- Parse goal number.
+	goal := parseInt(goalStr)
 
 	// Parse each component.
 	var components []int
-	for _, str := range strings.Split(colSplit[1], " ") {
-		if len(str) == 0 {
-			continue
+	for _, str := range componentStrs {
+		components = append(components, parseInt(str))
+	}
+
+	return equation{goal, components}
+}
+
+func (e equation) compute(operators []operator) (int, error) {
+	result := e.components[0]
+
+	for index, component := range e.components[1:] {
+		opFunc := operators[index].toFunc()
+
+		var ok bool
+		result, ok = opFunc(result, component)
+		if !ok {
+			if e.growsFrom(index + 1) {
+				return 0, errPastGoal
+			}
+			return 0, errOverflow
 		}
+	}
 
-		component, err := strconv.Atoi(str)
-		if err != nil {
-			panic(err)
+	return result, nil
+}
+
+func (e equation) growsFrom(index int) bool {
+	// -- No operator shrinks a non-negative value unless it meets a zero.
+	for i, component := range e.components {
+		if component < 0 || (i > index && component == 0) {
+			return false
 		}
-		components = append(components, component)
 	}
+	return true
+}
 
-	return equation{goal, components}
+func (e equation) isPossible() (bool, error) {
+	numOperations := len(e.components) - 1
+	permutationLimit, ok := powInt(2, numOperations)
+	if !ok {
+		return false, fmt.Errorf("%w: too many operators in %d", errOverflow, e.goal)
+	}
+	overflowed := false
+
+	for permutation := 0; permutation < permutationLimit; permutation += 1 {
+		operators := generateOperators(uint(permutation), numOperations)
+		result, err := e.compute(operators)
+		if errors.Is(err, errOverflow) {
+			overflowed = true
+		} else if err == nil && result == e.goal {
+			return true, nil
+		}
+	}
+
+	// -- An overflowed permutation might have matched had it been computed exactly.
+	if overflowed {
+		return false, fmt.Errorf("%w: cannot decide %d, rerun with -big", errOverflow, e.goal)
+	}
+	return false, nil
+}
+
+func bigAdd(a *big.Int, b *big.Int) *big.Int {
+	return new(big.Int).Add(a, b)
+}
+
+func bigMul(a *big.Int, b *big.Int) *big.Int {
+	return new(big.Int).Mul(a, b)
+}
+
+type bigEquation struct {
+	goal       *big.Int
+	components []*big.Int
+}
+
+func parseBigInt(str string) *big.Int {
+	num, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		panic(fmt.Errorf("invalid number: %q", str))
+	}
+	return num
+}
+
+func newBigEquation(line string) bigEquation {
+	goalStr, componentStrs := splitEquation(line)
+
+	// -- Parse goal number.
+	goal := parseBigInt(goalStr)
+
+	// Parse each component.
+	var components []*big.Int
+	for _, str := range componentStrs {
+		components = append(components, parseBigInt(str))
+	}
+
+	return bigEquation{goal, components}
 }
 
-func (e equation) compute(operators []operator) int {
+func (e bigEquation) compute(operators []operator) *big.Int {
 	result := e.components[0]
 
 	for index, component := range e.components[1:] {
-		opFunc := operators[index].toFunc()
+		opFunc := operators[index].toBigFunc()
 		result = opFunc(result, component)
 	}
 
 	return result
 }
 
-func (e equation) isPossible() bool {
+func (e bigEquation) isPossible() bool {
 	numOperations := len(e.components) - 1
-	permutationLimit := powInt(2, numOperations) - 1
+	permutationLimit, ok := powInt(2, numOperations)
+	if !ok {
+		panic(fmt.Errorf("%w: too many operators in %s", errOverflow, e.goal))
+	}
 
-	for permutation := 0; permutation <= permutationLimit; permutation += 1 {
+	for permutation := 0; permutation < permutationLimit; permutation += 1 {
 		operators := generateOperators(uint(permutation), numOperations)
-		if e.compute(operators) == e.goal {
+		if e.compute(operators).Cmp(e.goal) == 0 {
 			return true
 		}
 	}
 	return false
 }
 
-func main() {
-	// -- Parse equations.
-	var equations []equation
+func sumBig(lines []string) *big.Int {
+	sum := new(big.Int)
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		equation := newEquation(line)
-		equations = append(equations, equation)
+	for _, line := range lines {
+		equation := newBigEquation(line)
+		if equation.isPossible() {
+			sum.Add(sum, equation.goal)
+		}
+	}
+
+	return sum
+}
 
+func sumInt(lines []string) int {
+	// -- Parse equations.
+	var equations []equation
+	for _, line := range lines {
+		equations = append(equations, newEquation(line))
 	}
 
 	// -- Sum possible equations.
 	sum := 0
 
 	for _, equation := range equations {
-		if equation.isPossible() {
-			sum += equation.goal
+		possible, err := equation.isPossible()
+		if err != nil {
+			panic(err)
+		}
+
+		if possible {
+			var ok bool
+			sum, ok = op_add(sum, equation.goal)
+			if !ok {
+				panic(fmt.Errorf("%w: calibration total, rerun with -big", errOverflow))
+			}
 		}
 	}
 
-	fmt.Println(sum)
+	return sum
+}
+
+func main() {
+	useBig := flag.Bool("big", false, "use arbitrary-precision arithmetic")
+	flag.Parse()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if *useBig {
+		fmt.Println(sumBig(lines))
+	} else {
+		fmt.Println(sumInt(lines))
+	}
 }
@@ -2,15 +2,40 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func powInt(base int, exponent int) int {
-	return int(math.Pow(float64(base), float64(exponent)))
+var (
+	errOverflow = errors.New("integer overflow")
+	errPastGoal = errors.New("result passed goal")
+)
+
+func powInt(base int, exponent int) (int, bool) {
+	result := 1
+	for range exponent {
+		var ok bool
+		result, ok = op_mul(result, base)
+		if !ok {
+			return 0, false
+		}
+	}
+	return result, true
+}
+
+func numDigits(n int) int {
+	digits := 1
+	for n >= 10 {
+		n /= 10
+		digits += 1
+	}
+	return digits
 }
 
 type operator uint8
@@ -21,17 +46,36 @@ const (
 	cat
 )
 
-func op_add(a int, b int) int {
-	return a + b
+func op_add(a int, b int) (int, bool) {
+	if (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b) {
+		return 0, false
+	}
+	return a + b, true
 }
 
-func op_mul(a int, b int) int {
-	return a * b
+func op_mul(a int, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+
+	result := a * b
+	if result/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return result, true
 }
 
-func op_cat(a int, b int) int {
-	lenB := int(math.Log10(float64(b))) + 1
-	return a * powInt(10, lenB) + b
+func op_cat(a int, b int) (int, bool) {
+	shift, ok := powInt(10, numDigits(b))
+	if !ok {
+		return 0, false
+	}
+
+	shifted, ok := op_mul(a, shift)
+	if !ok {
+		return 0, false
+	}
+	return op_add(shifted, b)
 }
 
 func newOperator(num int) operator {
@@ -47,18 +91,18 @@ func newOperator(num int) operator {
 	}
 }
 
-func nextPermuatation(operators []int) []int {
+func nextPermuatation(operators []int) ([]int, bool) {
 	for index := range operators {
 		operators[index] += 1
 
 		if operators[index] > int(cat) {
 			operators[index] = 0
 		} else {
-			break
+			return operators, true
 		}
 	}
 
-	return operators
+	return operators, false
 }
 
 func generateOperators(opInts []int) []operator {
@@ -71,7 +115,7 @@ func generateOperators(opInts []int) []operator {
 	return operators
 }
 
-func (o operator) toFunc() func(int, int) int {
+func (o operator) toFunc() func(int, int) (int, bool) {
 	switch o {
 	case add:
 		return op_add
@@ -84,83 +128,247 @@ func (o operator) toFunc() func(int, int) int {
 	}
 }
 
+func (o operator) toBigFunc() func(*big.Int, *big.Int) *big.Int {
+	switch o {
+	case add:
+		return bigAdd
+	case mul:
+		return bigMul
+	case cat:
+		return bigCat
+	default:
+		panic("invalid operator")
+	}
+}
+
+func splitEquation(line string) (string, []string) {
+	colSplit := strings.Split(line, ":")
+
+	var components []string
+	for _, str := range strings.Split(colSplit[1], " ") {
+		if len(str) == 0 {
+			continue
+		}
+		components = append(components, str)
+	}
+
+	return colSplit[0], components
+}
+
 type equation struct {
 	goal       int
 	components []int
 }
 
-func newEquation(line string) equation {
-	colSplit := strings.Split(line, ":")
-
-	// -- Parse goal number.
-	goal, err := strconv.Atoi(colSplit[0])
+func parseInt(str string) int {
+	num, err := strconv.Atoi(str)
+	if errors.Is(err, strconv.ErrRange) {
+		panic(fmt.Errorf("%w: %s does not fit in int, rerun with -big", errOverflow, str))
+	}
 	if err != nil {
 		panic(err)
 	}
+	return num
+}
+
+func newEquation(line string) equation {
+	goalStr, componentStrs := splitEquation(line)
+
+	// -- Parse goal number.
+	goal := parseInt(goalStr)
 
 	// Parse each component.
 	var components []int
-	for _, str := range strings.Split(colSplit[1], " ") {
-		if len(str) == 0 {
-			continue
+	for _, str := range componentStrs {
+		components = append(components, parseInt(str))
+	}
+
+	return equation{goal, components}
+}
+
+func (e equation) compute(operators []operator) (int, error) {
+	result := e.components[0]
+
+	for index, component := range e.components[1:] {
+		opFunc := operators[index].toFunc()
+
+		var ok bool
+		result, ok = opFunc(result, component)
+		if !ok {
+			if e.growsFrom(index + 1) {
+				return 0, errPastGoal
+			}
+			return 0, errOverflow
 		}
+	}
 
-		component, err := strconv.Atoi(str)
-		if err != nil {
-			panic(err)
+	return result, nil
+}
+
+func (e equation) growsFrom(index int) bool {
+	// -- No operator shrinks a non-negative value unless it meets a zero.
+	for i, component := range e.components {
+		if component < 0 || (i > index && component == 0) {
+			return false
 		}
-		components = append(components, component)
 	}
+	return true
+}
 
-	return equation{goal, components}
+func (e equation) isPossible() (bool, error) {
+	numOperations := len(e.components) - 1
+	permutation := make([]int, numOperations)
+	overflowed := false
+
+	for {
+		operators := generateOperators(permutation)
+		result, err := e.compute(operators)
+		if errors.Is(err, errOverflow) {
+			overflowed = true
+		} else if err == nil && result == e.goal {
+			return true, nil
+		}
+
+		var ok bool
+		permutation, ok = nextPermuatation(permutation)
+		if !ok {
+			break
+		}
+	}
+
+	// -- An overflowed permutation might have matched had it been computed exactly.
+	if overflowed {
+		return false, fmt.Errorf("%w: cannot decide %d, rerun with -big", errOverflow, e.goal)
+	}
+	return false, nil
+}
+
+func bigAdd(a *big.Int, b *big.Int) *big.Int {
+	return new(big.Int).Add(a, b)
+}
+
+func bigMul(a *big.Int, b *big.Int) *big.Int {
+	return new(big.Int).Mul(a, b)
+}
+
+func bigCat(a *big.Int, b *big.Int) *big.Int {
+	lenB := int64(len(new(big.Int).Abs(b).String()))
+	shift := new(big.Int).Exp(big.NewInt(10), big.NewInt(lenB), nil)
+	return bigAdd(bigMul(a, shift), b)
+}
+
+type bigEquation struct {
+	goal       *big.Int
+	components []*big.Int
+}
+
+func parseBigInt(str string) *big.Int {
+	num, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		panic(fmt.Errorf("invalid number: %q", str))
+	}
+	return num
+}
+
+func newBigEquation(line string) bigEquation {
+	goalStr, componentStrs := splitEquation(line)
+
+	// -- Parse goal number.
+	goal := parseBigInt(goalStr)
+
+	// Parse each component.
+	var components []*big.Int
+	for _, str := range componentStrs {
+		components = append(components, parseBigInt(str))
+	}
+
+	return bigEquation{goal, components}
 }
 
-func (e equation) compute(operators []operator) int {
+func (e bigEquation) compute(operators []operator) *big.Int {
 	result := e.components[0]
 
 	for index, component := range e.components[1:] {
-		opFunc := operators[index].toFunc()
+		opFunc := operators[index].toBigFunc()
 		result = opFunc(result, component)
 	}
 
 	return result
 }
 
-func (e equation) isPossible() bool {
+func (e bigEquation) isPossible() bool {
 	numOperations := len(e.components) - 1
 	permutation := make([]int, numOperations)
-	permutationLimit := powInt(3, numOperations) - 1
 
-	for range permutationLimit + 1 {
+	for {
 		operators := generateOperators(permutation)
-		if e.compute(operators) == e.goal {
+		if e.compute(operators).Cmp(e.goal) == 0 {
 			return true
 		}
-		permutation = nextPermuatation(permutation)
+
+		var ok bool
+		permutation, ok = nextPermuatation(permutation)
+		if !ok {
+			return false
+		}
 	}
-	return false
 }
 
-func main() {
-	// -- Parse equations.
-	var equations []equation
+func sumBig(lines []string) *big.Int {
+	sum := new(big.Int)
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		equation := newEquation(line)
-		equations = append(equations, equation)
+	for _, line := range lines {
+		equation := newBigEquation(line)
+		if equation.isPossible() {
+			sum.Add(sum, equation.goal)
+		}
+	}
 
+	return sum
+}
+
+func sumInt(lines []string) int {
+	// -- Parse equations.
+	var equations []equation
+	for _, line := range lines {
+		equations = append(equations, newEquation(line))
 	}
 
 	// -- Sum possible equations.
 	sum := 0
 
 	for _, equation := range equations {
-		if equation.isPossible() {
-			sum += equation.goal
+		possible, err := equation.isPossible()
+		if err != nil {
+			panic(err)
+		}
+
+		if possible {
+			var ok bool
+			sum, ok = op_add(sum, equation.goal)
+			if !ok {
+				panic(fmt.Errorf("%w: calibration total, rerun with -big", errOverflow))
+			}
 		}
 	}
 
-	fmt.Println(sum)
+	return sum
+}
+
+func main() {
+	useBig := flag.Bool("big", false, "use arbitrary-precision arithmetic")
+	flag.Parse()
+
+	var lines []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if *useBig {
+		fmt.Println(sumBig(lines))
+	} else {
+		fmt.Println(sumInt(lines))
+	}
 }
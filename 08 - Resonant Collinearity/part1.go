@@ -2,10 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
-	"math/bits"
 	"os"
+	"strconv"
+	"strings"
+
+	"aoc2024/combinatorics"
 )
 
 type set[T comparable] map[T]struct{}
@@ -18,127 +22,237 @@ func (s set[T]) insert(value T) {
 	s[value] = struct{}{}
 }
 
-func generateCombinations[T any](arr []T, size int) <-chan []T {
-	// -- Normalize requested size.
-	length := uint(len(arr))
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
 
-	if size > len(arr) {
-		size = len(arr)
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
 	}
+	return absInt(a)
+}
 
-	// -- Output to channel.
-	out := make(chan []T)
+type point []int
 
-	go func() {
-		defer close(out)
+func (p point) step(delta point, times int) point {
+	res := make(point, len(p))
+	for axis := range p {
+		res[axis] = p[axis] + delta[axis]*times
+	}
+	return res
+}
 
-		for comboBits := 1; comboBits < (1 << length); comboBits += 1 {
-			if size > 0 && bits.OnesCount(uint(comboBits)) != size {
-				continue
-			}
+func (p point) key() string {
+	return fmt.Sprint([]int(p))
+}
 
-			var combo []T
+type resonance struct {
+	minHarmonic int
+	maxHarmonic int
+	lattice     bool
+}
 
-			for index := uint(0); index < length; index += 1 {
-				if (comboBits>>index)&1 == 1 {
-					combo = append(combo, arr[index])
-				}
-			}
+var (
+	twiceDistance = resonance{minHarmonic: 1, maxHarmonic: 1}
+	anyMultiple   = resonance{minHarmonic: 0, maxHarmonic: -1}
+	fullLine      = resonance{lattice: true}
+)
+
+func parseResonance(harmonics string) resonance {
+	// -- Accept "k", "lo-hi" or open-ended "lo-".
+	lo, hi, isRange := strings.Cut(harmonics, "-")
+
+	minHarmonic, err := strconv.Atoi(lo)
+	if err != nil {
+		panic(err)
+	}
+	if minHarmonic < 0 {
+		panic("harmonics must not be negative")
+	}
 
-			out <- combo
+	maxHarmonic := minHarmonic
+	if isRange {
+		maxHarmonic = -1
+		if len(hi) != 0 {
+			maxHarmonic, err = strconv.Atoi(hi)
+			if err != nil {
+				panic(err)
+			}
 		}
-	}()
+	}
 
-	return out
+	return resonance{minHarmonic, maxHarmonic, false}
 }
 
-type coord struct {
-	row int
-	col int
+func (r resonance) inRange(harmonic int) bool {
+	return r.maxHarmonic < 0 || harmonic <= r.maxHarmonic
 }
 
-type slope struct {
-	rise int
-	run  int
+type line struct {
+	start point
+	end   point
 }
 
-type line struct {
-	start coord
-	end   coord
+func (l line) getSlope() point {
+	slope := make(point, len(l.start))
+	for axis := range slope {
+		slope[axis] = l.end[axis] - l.start[axis]
+	}
+	return slope
 }
 
-func (l line) getSlope() slope {
-	rise := l.end.row - l.start.row
-	run := l.end.col - l.start.col
-	return slope{rise, run}
+func (l line) getReducedSlope() point {
+	slope := l.getSlope()
+
+	divisor := 0
+	for _, delta := range slope {
+		divisor = gcd(divisor, delta)
+	}
+
+	for axis := range slope {
+		slope[axis] /= divisor
+	}
+	return slope
 }
 
-func (l line) getAntiNodes() [2]coord {
+func (l line) getAntiNodes(cm cityMap, r resonance) []point {
+	var antinodes []point
+
+	// -- Every lattice point on the line is an antinode.
+	if r.lattice {
+		slope := l.getReducedSlope()
+
+		for pos := l.start; cm.inBounds(pos); pos = pos.step(slope, +1) {
+			antinodes = append(antinodes, pos)
+		}
+
+		for pos := l.start.step(slope, -1); cm.inBounds(pos); pos = pos.step(slope, -1) {
+			antinodes = append(antinodes, pos)
+		}
+
+		return antinodes
+	}
+
+	// -- Harmonic k lies k times the antenna distance beyond each antenna.
 	slope := l.getSlope()
-	a_row := l.start.row - slope.rise
-	a_col := l.start.col - slope.run
-	b_row := l.end.row + slope.rise
-	b_col := l.end.col + slope.run
-	return [2]coord{{a_row, a_col}, {b_row, b_col}}
+
+	for harmonic := r.minHarmonic; r.inRange(harmonic); harmonic += 1 {
+		rev := l.start.step(slope, -harmonic)
+		fwd := l.end.step(slope, +harmonic)
+
+		if !cm.inBounds(rev) && !cm.inBounds(fwd) {
+			break
+		}
+
+		if cm.inBounds(rev) {
+			antinodes = append(antinodes, rev)
+		}
+
+		if cm.inBounds(fwd) {
+			antinodes = append(antinodes, fwd)
+		}
+	}
+
+	return antinodes
 }
 
 type cityMap struct {
-	numRows  int
-	numCols  int
-	antennas map[byte][]coord
+	bounds   []int
+	antennas map[byte][]point
 }
 
 func newCityMap(r io.Reader) cityMap {
+	layer := 0
 	row := 0
 	col := 0
-	antennas := make(map[byte][]coord)
+	antennas := make(map[byte][]point)
 
+	// -- Blank-line separated grids stack into a third dimension.
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
+		if len(line) == 0 {
+			if row != 0 {
+				layer += 1
+				row = 0
+			}
+			continue
+		}
+
 		for index, char := range line {
 			char := byte(char)
 			if char == '.' {
 				continue
 			}
 
-			antennas[char] = append(antennas[char], coord{row, index})
+			antennas[char] = append(antennas[char], point{layer, row, index})
 		}
 
 		row += 1
 		col = len(line)
 	}
 
-	return cityMap{row, col, antennas}
-}
+	if row != 0 {
+		layer += 1
+	}
+
+	if layer > 1 {
+		return cityMap{[]int{layer, row, col}, antennas}
+	}
+
+	// -- Single grids stay two dimensional.
+	for char, points := range antennas {
+		for index, pos := range points {
+			antennas[char][index] = pos[1:]
+		}
+	}
 
-func (cm cityMap) inBounds(pos coord) bool {
-	return pos.row >= 0 && pos.col >= 0 && pos.row < cm.numRows && pos.col < cm.numCols
+	return cityMap{[]int{row, col}, antennas}
 }
 
-func (cm cityMap) getAntiNodes() set[coord] {
-	antinodes := newSet[coord]()
+func (cm cityMap) inBounds(pos point) bool {
+	for axis, limit := range cm.bounds {
+		if pos[axis] < 0 || pos[axis] >= limit {
+			return false
+		}
+	}
+	return true
+}
 
-	for _, coords := range cm.antennas {
-		for combo := range generateCombinations(coords, 2) {
-			if len(combo) != 2 {
-				continue
-			}
+func (cm cityMap) getAntiNodes(r resonance) set[string] {
+	antinodes := newSet[string]()
 
+	for _, points := range cm.antennas {
+		for combo := range combinatorics.Combinations(points, 2) {
 			line := line{combo[0], combo[1]}
 
-			for _, antinode := range line.getAntiNodes() {
-				if cm.inBounds(antinode) {
-					antinodes.insert(antinode)
-				}
+			for _, antinode := range line.getAntiNodes(cm, r) {
+				antinodes.insert(antinode.key())
 			}
 		}
 	}
+
 	return antinodes
 }
 
 func main() {
+	harmonics := flag.String("harmonics", "", "harmonic multiples as k, lo-hi or lo-")
+	lattice := flag.Bool("lattice", false, "count every lattice point on each antenna line")
+	flag.Parse()
+
+	rule := twiceDistance
+	if *lattice {
+		rule = fullLine
+	} else if len(*harmonics) != 0 {
+		rule = parseResonance(*harmonics)
+	}
+
 	cityMap := newCityMap(os.Stdin)
-	antinodes := cityMap.getAntiNodes()
+
+	antinodes := cityMap.getAntiNodes(rule)
 	fmt.Println(len(antinodes))
 }
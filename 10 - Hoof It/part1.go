@@ -2,10 +2,15 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type set[T comparable] map[T]struct{}
@@ -30,9 +35,14 @@ const (
 	EAST
 	SOUTH
 	WEST
+	NORTH_EAST
+	SOUTH_EAST
+	SOUTH_WEST
+	NORTH_WEST
 )
 
 var directions = [...]direction{NORTH, EAST, SOUTH, WEST}
+var diagonals = [...]direction{NORTH_EAST, SOUTH_EAST, SOUTH_WEST, NORTH_WEST}
 
 func (d direction) getDelta() coord {
 	switch d {
@@ -44,6 +54,14 @@ func (d direction) getDelta() coord {
 		return coord{1, 0}
 	case WEST:
 		return coord{0, -1}
+	case NORTH_EAST:
+		return coord{-1, 1}
+	case SOUTH_EAST:
+		return coord{1, 1}
+	case SOUTH_WEST:
+		return coord{1, -1}
+	case NORTH_WEST:
+		return coord{-1, -1}
 	default:
 		panic("invalid direction")
 	}
@@ -54,21 +72,104 @@ type coord struct {
 	col int
 }
 
-func (c coord) next(dir direction) coord {
+func (c coord) next(dir direction, distance int) coord {
 	delta := dir.getDelta()
-	c.row += delta.row
-	c.col += delta.col
+	c.row += delta.row * distance
+	c.col += delta.col * distance
 	return c
 }
 
+type trailRule struct {
+	topoDiffs []int
+	distances []int
+	diagonal  bool
+	startTopo int
+	endTopo   int
+	cellWidth int
+}
+
+var hikingTrail = trailRule{
+	topoDiffs: []int{1},
+	distances: []int{1},
+	diagonal:  false,
+	startTopo: 0,
+	endTopo:   9,
+	cellWidth: 1,
+}
+
+func parseInts(str string) []int {
+	var nums []int
+
+	for _, field := range strings.Split(str, ",") {
+		num, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			panic(err)
+		}
+		nums = append(nums, num)
+	}
+
+	return nums
+}
+
+func formatInts(nums []int) string {
+	strs := make([]string, 0, len(nums))
+	for _, num := range nums {
+		strs = append(strs, strconv.Itoa(num))
+	}
+	return strings.Join(strs, ",")
+}
+
+func (tr trailRule) validate() {
+	if len(tr.topoDiffs) == 0 || len(tr.distances) == 0 {
+		panic("trail rule needs at least one height difference and distance")
+	}
+
+	for _, distance := range tr.distances {
+		if distance <= 0 {
+			panic("distances must be positive")
+		}
+	}
+
+	if tr.cellWidth <= 0 {
+		panic("cell width must be positive")
+	}
+}
+
+// -- Mixing ascents and descents lets trails loop, so only those that don't can be rated.
+const unrated = "ratings and trails need height differences that all ascend or all descend"
+
+func (tr trailRule) monotone() bool {
+	for _, topoDiff := range tr.topoDiffs {
+		if topoDiff == 0 || (topoDiff > 0) != (tr.topoDiffs[0] > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func (tr trailRule) ascending() bool {
+	return tr.topoDiffs[0] > 0
+}
+
+func (tr trailRule) directions() []direction {
+	dirs := directions[:]
+	if tr.diagonal {
+		dirs = append(dirs, diagonals[:]...)
+	}
+	return dirs
+}
+
 type topoMap struct {
 	grid    [][]int
 	start   []coord
 	numRows int
 	numCols int
+	rule    trailRule
 }
 
-func newTopoMap(reader io.Reader) topoMap {
+func newTopoMap(reader io.Reader, rule trailRule) topoMap {
+	rule.validate()
+
 	row := 0
 	var grid [][]int
 	var start []coord
@@ -76,15 +177,19 @@ func newTopoMap(reader io.Reader) topoMap {
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
 		line := scanner.Text()
-		curr := make([]int, 0, len(line))
+		if len(line)%rule.cellWidth != 0 {
+			panic(fmt.Sprintf("row %d is not a multiple of %d characters", row, rule.cellWidth))
+		}
+		curr := make([]int, 0, len(line)/rule.cellWidth)
 
-		for col, ch := range line {
-			num, err := strconv.Atoi(string(ch))
+		for col := range len(line) / rule.cellWidth {
+			cell := line[col*rule.cellWidth : (col+1)*rule.cellWidth]
+			num, err := strconv.Atoi(cell)
 			if err != nil {
 				panic(err)
 			}
 
-			if num == 0 {
+			if num == rule.startTopo {
 				start = append(start, coord{row, col})
 			}
 
@@ -95,7 +200,7 @@ func newTopoMap(reader io.Reader) topoMap {
 		row += 1
 	}
 
-	return topoMap{grid, start, row, len(grid[0])}
+	return topoMap{grid, start, row, len(grid[0]), rule}
 }
 
 func (tm topoMap) inBounds(pos coord) bool {
@@ -106,58 +211,278 @@ func (tm topoMap) getTopo(pos coord) int {
 	return tm.grid[pos.row][pos.col]
 }
 
-func (tm topoMap) scoreTrailhead(trailhead coord) int {
-	type todo struct {
-		pos coord
-		topo int
+func (tm topoMap) moves(pos coord) []coord {
+	var next []coord
+	currTopo := tm.getTopo(pos)
+
+	// -- Trails stop once they reach the end height.
+	if currTopo == tm.rule.endTopo {
+		return next
 	}
 
-	score := 0
-	visited := newSet[coord]()
-	visited.insert(trailhead)
-	queue := []todo{{trailhead, tm.getTopo(trailhead)}}
+	for _, direction := range tm.rule.directions() {
+		for _, distance := range tm.rule.distances {
+			nextPos := pos.next(direction, distance)
+			if !tm.inBounds(nextPos) {
+				continue
+			}
 
-	for len(queue) != 0 {
-		curr := queue[0]
-		queue = queue[1:]
+			topoDiff := tm.getTopo(nextPos) - currTopo
+			if !slices.Contains(tm.rule.topoDiffs, topoDiff) {
+				continue
+			}
 
-		if curr.topo == 9 {
-			score += 1
+			next = append(next, nextPos)
 		}
+	}
 
-		for _, direction := range directions {
-			nextPos := curr.pos.next(direction)
-			if visited.contains(nextPos) || !tm.inBounds(nextPos) {
-				continue
-			}
+	return next
+}
 
-			nextTopo := tm.getTopo(nextPos)
-			topoDiff := nextTopo - curr.topo
-			if topoDiff != 1 {
-				continue
+type trailStats struct {
+	ends    map[coord]set[coord]
+	ratings map[coord]int
+}
+
+func (tm topoMap) reachableEnds(trailhead coord) set[coord] {
+	ends := newSet[coord]()
+	seen := newSet[coord]()
+	seen.insert(trailhead)
+	open := []coord{trailhead}
+
+	for len(open) != 0 {
+		pos := open[0]
+		open = open[1:]
+
+		if tm.getTopo(pos) == tm.rule.endTopo {
+			ends.insert(pos)
+		}
+
+		for _, nextPos := range tm.moves(pos) {
+			if !seen.contains(nextPos) {
+				seen.insert(nextPos)
+				open = append(open, nextPos)
 			}
+		}
+	}
+
+	return ends
+}
+
+func (tm topoMap) analyze() trailStats {
+	ends := make(map[coord]set[coord])
+
+	// -- Trails that can loop have no order to follow, so search from each trailhead instead.
+	if !tm.rule.monotone() {
+		for _, trailhead := range tm.start {
+			ends[trailhead] = tm.reachableEnds(trailhead)
+		}
+		return trailStats{ends, nil}
+	}
+
+	ratings := make(map[coord]int)
+
+	// -- Order positions against the trail direction so every next step is already known.
+	var order []coord
+	for row := range tm.numRows {
+		for col := range tm.numCols {
+			order = append(order, coord{row, col})
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if tm.rule.ascending() {
+			return tm.getTopo(order[i]) > tm.getTopo(order[j])
+		}
+		return tm.getTopo(order[i]) < tm.getTopo(order[j])
+	})
+
+	for _, pos := range order {
+		reachable := newSet[coord]()
+
+		if tm.getTopo(pos) == tm.rule.endTopo {
+			reachable.insert(pos)
+			ratings[pos] = 1
+		}
 
-			queue = append(queue, todo{nextPos, nextTopo})
-			visited.insert(nextPos)
+		for _, nextPos := range tm.moves(pos) {
+			for end := range ends[nextPos] {
+				reachable.insert(end)
+			}
+			ratings[pos] += ratings[nextPos]
 		}
+
+		ends[pos] = reachable
 	}
 
-	return score
+	return trailStats{ends, ratings}
+}
+
+func (ts trailStats) score(trailhead coord) int {
+	return len(ts.ends[trailhead])
+}
+
+func (ts trailStats) rating(trailhead coord) int {
+	if ts.ratings == nil {
+		panic(unrated)
+	}
+	return ts.ratings[trailhead]
 }
 
 func (tm topoMap) totalScore() int {
+	stats := tm.analyze()
 	sum := 0
 
 	for _, trailhead := range tm.start {
-		score := tm.scoreTrailhead(trailhead)
-		sum += score
+		sum += stats.score(trailhead)
 	}
 
 	return sum
 }
 
+func (tm topoMap) totalRating() int {
+	stats := tm.analyze()
+	sum := 0
+
+	for _, trailhead := range tm.start {
+		sum += stats.rating(trailhead)
+	}
+
+	return sum
+}
+
+type trail []coord
+
+func (tm topoMap) trails(trailhead coord) []trail {
+	if !tm.rule.monotone() {
+		panic(unrated)
+	}
+
+	var trails []trail
+
+	var walk func(path trail)
+	walk = func(path trail) {
+		currPos := path[len(path)-1]
+		if tm.getTopo(currPos) == tm.rule.endTopo {
+			trails = append(trails, slices.Clone(path))
+			return
+		}
+
+		for _, nextPos := range tm.moves(currPos) {
+			walk(append(path, nextPos))
+		}
+	}
+
+	walk(trail{trailhead})
+	return trails
+}
+
+func (tm topoMap) render(trails []trail) string {
+	onTrail := newSet[coord]()
+	for _, trail := range trails {
+		for _, pos := range trail {
+			onTrail.insert(pos)
+		}
+	}
+
+	var sb strings.Builder
+
+	for row := range tm.numRows {
+		for col := range tm.numCols {
+			pos := coord{row, col}
+			if onTrail.contains(pos) {
+				fmt.Fprintf(&sb, "%0*d", tm.rule.cellWidth, tm.getTopo(pos))
+			} else {
+				sb.WriteString(strings.Repeat(".", tm.rule.cellWidth))
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+type trailheadReport struct {
+	Row    int        `json:"row"`
+	Col    int        `json:"col"`
+	Score  int        `json:"score"`
+	Rating int        `json:"rating"`
+	Trails [][][2]int `json:"trails"`
+}
+
+type mapReport struct {
+	Score      int               `json:"score"`
+	Rating     int               `json:"rating"`
+	Trailheads []trailheadReport `json:"trailheads"`
+}
+
+func (tm topoMap) report() mapReport {
+	stats := tm.analyze()
+	var report mapReport
+
+	for _, trailhead := range tm.start {
+		entry := trailheadReport{
+			Row:    trailhead.row,
+			Col:    trailhead.col,
+			Score:  stats.score(trailhead),
+			Rating: stats.rating(trailhead),
+			Trails: [][][2]int{},
+		}
+
+		for _, trail := range tm.trails(trailhead) {
+			steps := make([][2]int, 0, len(trail))
+			for _, pos := range trail {
+				steps = append(steps, [2]int{pos.row, pos.col})
+			}
+			entry.Trails = append(entry.Trails, steps)
+		}
+
+		report.Score += entry.Score
+		report.Rating += entry.Rating
+		report.Trailheads = append(report.Trailheads, entry)
+	}
+
+	return report
+}
+
 func main() {
-	tm := newTopoMap(os.Stdin)
-	score := tm.totalScore()
-	fmt.Println(score)
+	asJSON := flag.Bool("json", false, "print every trailhead with its trails as JSON")
+	showTrails := flag.Bool("trails", false, "render the trails of every trailhead over the map")
+	topoDiffs := flag.String("diffs", formatInts(hikingTrail.topoDiffs), "comma separated height differences allowed per step; ratings and trails need them all ascending or all descending")
+	distances := flag.String("distances", formatInts(hikingTrail.distances), "comma separated distances a step may cover")
+	diagonal := flag.Bool("diagonal", hikingTrail.diagonal, "allow diagonal steps")
+	startTopo := flag.Int("start", hikingTrail.startTopo, "height trails start at")
+	endTopo := flag.Int("end", hikingTrail.endTopo, "height trails end at")
+	cellWidth := flag.Int("width", hikingTrail.cellWidth, "characters per height in the map")
+	flag.Parse()
+
+	rule := trailRule{
+		topoDiffs: parseInts(*topoDiffs),
+		distances: parseInts(*distances),
+		diagonal:  *diagonal,
+		startTopo: *startTopo,
+		endTopo:   *endTopo,
+		cellWidth: *cellWidth,
+	}
+
+	tm := newTopoMap(os.Stdin, rule)
+
+	switch {
+	case *asJSON:
+		out, err := json.MarshalIndent(tm.report(), "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(out))
+	case *showTrails:
+		stats := tm.analyze()
+		for _, trailhead := range tm.start {
+			fmt.Printf("trailhead (%d, %d): score %d, rating %d\n",
+				trailhead.row, trailhead.col, stats.score(trailhead), stats.rating(trailhead))
+			fmt.Println(tm.render(tm.trails(trailhead)))
+		}
+	default:
+		score := tm.totalScore()
+		fmt.Println(score)
+	}
 }
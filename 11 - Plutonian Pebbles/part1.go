@@ -2,81 +2,440 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
 )
 
+var (
+	errOverflow = errors.New("integer overflow")
+	errNegative = errors.New("negative stone")
+)
+
+type stoneValue interface {
+	numDigits() int
+	split() (stoneValue, stoneValue)
+	cmp(n int) int
+	mod(m int) int
+	add(n int) stoneValue
+	mul(n int) stoneValue
+}
+
 type stone int
 
-func powStone(base stone, exponent stone) stone {
-	return stone(math.Pow(float64(base), float64(exponent)))
+func pow10(exponent int) stone {
+	result := stone(1)
+	for range exponent {
+		result = result.mul(10).(stone)
+	}
+	return result
+}
+
+func (s stone) numDigits() int {
+	digits := 1
+	for s >= 10 {
+		s /= 10
+		digits += 1
+	}
+	return digits
+}
+
+func (s stone) split() (stoneValue, stoneValue) {
+	base10 := pow10(s.numDigits() - s.numDigits()/2)
+	return s / base10, s % base10
+}
+
+func (s stone) cmp(n int) int {
+	switch {
+	case int(s) < n:
+		return -1
+	case int(s) > n:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s stone) mod(m int) int {
+	return int(s) % m
+}
+
+func (s stone) add(n int) stoneValue {
+	if (n > 0 && int(s) > math.MaxInt-n) || (n < 0 && int(s) < math.MinInt-n) {
+		panic(fmt.Errorf("%w: %d + %d, rerun with -big", errOverflow, s, n))
+	}
+	return s + stone(n)
+}
+
+func (s stone) mul(n int) stoneValue {
+	if s == 0 || n == 0 {
+		return stone(0)
+	}
+
+	result := s * stone(n)
+	if int(result)/n != int(s) || (s == -1 && n == math.MinInt) || (n == -1 && s == math.MinInt) {
+		panic(fmt.Errorf("%w: %d * %d, rerun with -big", errOverflow, s, n))
+	}
+	return result
+}
+
+type bigStone string
+
+func newBigStone(n *big.Int) bigStone {
+	return bigStone(n.String())
+}
+
+func (s bigStone) toBig() *big.Int {
+	n, ok := new(big.Int).SetString(string(s), 10)
+	if !ok {
+		panic(fmt.Errorf("invalid stone: %q", string(s)))
+	}
+	return n
+}
+
+func (s bigStone) numDigits() int {
+	return len(s)
+}
+
+func (s bigStone) split() (stoneValue, stoneValue) {
+	half := len(s) / 2
+	left := s[:half]
+	if len(left) == 0 {
+		left = "0"
+	}
+
+	// -- Drop leading zeros from the right half.
+	right := strings.TrimLeft(string(s[half:]), "0")
+	if len(right) == 0 {
+		right = "0"
+	}
+
+	return left, bigStone(right)
+}
+
+func (s bigStone) cmp(n int) int {
+	return s.toBig().Cmp(big.NewInt(int64(n)))
+}
+
+func (s bigStone) mod(m int) int {
+	return int(new(big.Int).Mod(s.toBig(), big.NewInt(int64(m))).Int64())
+}
+
+func (s bigStone) add(n int) stoneValue {
+	return newBigStone(new(big.Int).Add(s.toBig(), big.NewInt(int64(n))))
+}
+
+func (s bigStone) mul(n int) stoneValue {
+	return newBigStone(new(big.Int).Mul(s.toBig(), big.NewInt(int64(n))))
+}
+
+func parseStone(str string, useBig bool) stoneValue {
+	// -- Digits and halves are only defined for engravings without a sign.
+	if strings.HasPrefix(str, "-") {
+		panic(fmt.Errorf("%w: %s", errNegative, str))
+	}
+
+	if useBig {
+		n, ok := new(big.Int).SetString(str, 10)
+		if !ok {
+			panic(fmt.Errorf("invalid stone: %q", str))
+		}
+		return newBigStone(n)
+	}
+
+	num, err := strconv.Atoi(str)
+	if errors.Is(err, strconv.ErrRange) {
+		panic(fmt.Errorf("%w: %s does not fit in int, rerun with -big", errOverflow, str))
+	}
+	if err != nil {
+		panic(err)
+	}
+	return stone(num)
+}
+
+const defaultRules = `
+value == 0      -> 1
+digits % 2 == 0 -> left, right
+*               -> value * 2024
+`
+
+type condition struct {
+	subject string
+	modulus int
+	op      string
+	operand int
+}
+
+func parseCondition(str string) condition {
+	fields := strings.Fields(str)
+
+	var cond condition
+	switch len(fields) {
+	case 3:
+		cond = condition{fields[0], 0, fields[1], 0}
+	case 5:
+		if fields[1] != "%" {
+			panic(fmt.Errorf("invalid condition: %q", str))
+		}
+		modulus, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic(err)
+		}
+		if modulus <= 0 {
+			panic(fmt.Errorf("invalid modulus in condition: %q", str))
+		}
+		cond = condition{fields[0], modulus, fields[3], 0}
+	default:
+		panic(fmt.Errorf("invalid condition: %q", str))
+	}
+
+	if cond.subject != "value" && cond.subject != "digits" {
+		panic(fmt.Errorf("unknown subject in condition: %q", str))
+	}
+
+	switch cond.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		panic(fmt.Errorf("unknown comparison in condition: %q", str))
+	}
+
+	operand, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		panic(err)
+	}
+	cond.operand = operand
+
+	return cond
+}
+
+func (c condition) matches(s stoneValue) bool {
+	var diff int
+
+	switch {
+	case c.subject == "digits" && c.modulus != 0:
+		diff = s.numDigits()%c.modulus - c.operand
+	case c.subject == "digits":
+		diff = s.numDigits() - c.operand
+	case c.modulus != 0:
+		diff = s.mod(c.modulus) - c.operand
+	default:
+		diff = s.cmp(c.operand)
+	}
+
+	switch c.op {
+	case "==":
+		return diff == 0
+	case "!=":
+		return diff != 0
+	case "<":
+		return diff < 0
+	case "<=":
+		return diff <= 0
+	case ">":
+		return diff > 0
+	default:
+		return diff >= 0
+	}
+}
+
+type output struct {
+	base    string
+	op      string
+	operand int
+}
+
+func parseOutput(str string) output {
+	fields := strings.Fields(str)
+
+	var out output
+	switch len(fields) {
+	case 1:
+		out = output{fields[0], "", 0}
+	case 3:
+		operand, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic(err)
+		}
+		out = output{fields[0], fields[1], operand}
+	default:
+		panic(fmt.Errorf("invalid output: %q", str))
+	}
+
+	switch out.base {
+	case "value", "left", "right":
+	default:
+		if _, ok := new(big.Int).SetString(out.base, 10); !ok {
+			panic(fmt.Errorf("unknown stone in output: %q", str))
+		}
+	}
+
+	if out.op != "" && out.op != "+" && out.op != "*" {
+		panic(fmt.Errorf("unknown operator in output: %q", str))
+	}
+
+	return out
 }
 
-func (s stone) transform() []stone {
-	// -- Rule 1.
-	if s == 0 {
-		return []stone{1}
+func (o output) apply(s stoneValue, useBig bool) stoneValue {
+	var result stoneValue
+
+	switch o.base {
+	case "value":
+		result = s
+	case "left":
+		result, _ = s.split()
+	case "right":
+		_, result = s.split()
+	default:
+		result = parseStone(o.base, useBig)
+	}
+
+	switch o.op {
+	case "+":
+		result = result.add(o.operand)
+	case "*":
+		result = result.mul(o.operand)
 	}
 
-	// -- Rule 2.
-	numDigits := stone(math.Log10(float64(s))) + 1
-	if numDigits%2 == 0 {
-		halfLen := numDigits / 2
-		base10 := powStone(10, halfLen)
-		left := s / base10
-		right := s - (left * base10)
-		return []stone{left, right}
+	if result.cmp(0) < 0 {
+		panic(fmt.Errorf("%w: rule output %s %s %d", errNegative, o.base, o.op, o.operand))
 	}
+	return result
+}
+
+type rule struct {
+	conditions []condition
+	outputs    []output
+}
 
-	// Rule 3.
-	return []stone{s * 2024}
+type ruleSet struct {
+	rules  []rule
+	useBig bool
 }
 
-type stoneLine []stone
+func newRuleSet(r io.Reader, useBig bool) ruleSet {
+	var rules []rule
 
-func newStoneLine(r io.Reader) stoneLine {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		condStr, outStr, ok := strings.Cut(line, "->")
+		if !ok {
+			panic(fmt.Errorf("rule is missing '->': %q", line))
+		}
+
+		// -- Parse conditions, "*" matches every stone.
+		var conditions []condition
+		if strings.TrimSpace(condStr) != "*" {
+			for _, str := range strings.Split(condStr, " and ") {
+				conditions = append(conditions, parseCondition(str))
+			}
+		}
+
+		// -- Parse output stones.
+		var outputs []output
+		for _, str := range strings.Split(outStr, ",") {
+			outputs = append(outputs, parseOutput(str))
+		}
+
+		rules = append(rules, rule{conditions, outputs})
+	}
+
+	return ruleSet{rules, useBig}
+}
+
+func (rs ruleSet) parseStone(str string) stoneValue {
+	return parseStone(str, rs.useBig)
+}
+
+func (rs ruleSet) transform(s stoneValue) []stoneValue {
+	// -- First matching rule wins, stones matching no rule stay as they are.
+	for _, rule := range rs.rules {
+		matched := true
+		for _, cond := range rule.conditions {
+			if !cond.matches(s) {
+				matched = false
+				break
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		stones := make([]stoneValue, 0, len(rule.outputs))
+		for _, out := range rule.outputs {
+			stones = append(stones, out.apply(s, rs.useBig))
+		}
+		return stones
+	}
+
+	return []stoneValue{s}
+}
+
+type stoneLine []stoneValue
+
+func newStoneLine(r io.Reader, rs ruleSet) stoneLine {
 	scanner := bufio.NewScanner(r)
 	if !scanner.Scan() {
 		panic("no input")
 	}
 	line := scanner.Text()
 	strs := strings.Split(line, " ")
-	stones := make([]stone, 0, len(strs))
 
+	stones := make([]stoneValue, 0, len(strs))
 	for _, str := range strs {
-		num, err := strconv.Atoi(str)
-		if err != nil {
-			panic(err)
-		}
-		stones = append(stones, stone(num))
+		stones = append(stones, rs.parseStone(str))
 	}
 
 	return stones
 }
 
-func (sl *stoneLine) transform() {
-	newStones := make([]stone, 0, len(*sl)*2)
+func (sl *stoneLine) transform(rs ruleSet) {
+	newStones := make([]stoneValue, 0, len(*sl)*2)
 
 	for _, stone := range *sl {
-		transformed := stone.transform()
+		transformed := rs.transform(stone)
 		newStones = append(newStones, transformed...)
 	}
 
 	*sl = newStones
 }
 
-func (sl *stoneLine) doTransforms(times int) {
+func (sl *stoneLine) doTransforms(rs ruleSet, times int) {
 	for range times {
-		sl.transform()
+		sl.transform(rs)
 	}
 }
 
 func main() {
-	stones := newStoneLine(os.Stdin)
-	stones.doTransforms(25)
+	rulesPath := flag.String("rules", "", "file of blink rules, defaults to the puzzle rules")
+	useBig := flag.Bool("big", false, "use arbitrary-precision stones")
+	blinks := flag.Int("blinks", 25, "number of blinks")
+	flag.Parse()
+
+	var rulesReader io.Reader = strings.NewReader(defaultRules)
+	if len(*rulesPath) != 0 {
+		file, err := os.Open(*rulesPath)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		rulesReader = file
+	}
+
+	rules := newRuleSet(rulesReader, *useBig)
+	stones := newStoneLine(os.Stdin, rules)
+	stones.doTransforms(rules, *blinks)
 	fmt.Println(len(stones))
 }
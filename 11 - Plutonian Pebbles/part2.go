@@ -2,44 +2,394 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"math"
+	"math/big"
+	"math/bits"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
 
+var (
+	errOverflow = errors.New("integer overflow")
+	errNegative = errors.New("negative stone")
+)
+
+type stoneValue interface {
+	numDigits() int
+	split() (stoneValue, stoneValue)
+	cmp(n int) int
+	mod(m int) int
+	add(n int) stoneValue
+	mul(n int) stoneValue
+}
+
 type stone int
 
-func powStone(base stone, exponent stone) stone {
-	return stone(math.Pow(float64(base), float64(exponent)))
+func pow10(exponent int) stone {
+	result := stone(1)
+	for range exponent {
+		result = result.mul(10).(stone)
+	}
+	return result
+}
+
+func (s stone) numDigits() int {
+	digits := 1
+	for s >= 10 {
+		s /= 10
+		digits += 1
+	}
+	return digits
+}
+
+func (s stone) split() (stoneValue, stoneValue) {
+	base10 := pow10(s.numDigits() - s.numDigits()/2)
+	return s / base10, s % base10
+}
+
+func (s stone) cmp(n int) int {
+	switch {
+	case int(s) < n:
+		return -1
+	case int(s) > n:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (s stone) mod(m int) int {
+	return int(s) % m
+}
+
+func (s stone) add(n int) stoneValue {
+	if (n > 0 && int(s) > math.MaxInt-n) || (n < 0 && int(s) < math.MinInt-n) {
+		panic(fmt.Errorf("%w: %d + %d, rerun with -big", errOverflow, s, n))
+	}
+	return s + stone(n)
+}
+
+func (s stone) mul(n int) stoneValue {
+	if s == 0 || n == 0 {
+		return stone(0)
+	}
+
+	result := s * stone(n)
+	if int(result)/n != int(s) || (s == -1 && n == math.MinInt) || (n == -1 && s == math.MinInt) {
+		panic(fmt.Errorf("%w: %d * %d, rerun with -big", errOverflow, s, n))
+	}
+	return result
+}
+
+type bigStone string
+
+func newBigStone(n *big.Int) bigStone {
+	return bigStone(n.String())
+}
+
+func (s bigStone) toBig() *big.Int {
+	n, ok := new(big.Int).SetString(string(s), 10)
+	if !ok {
+		panic(fmt.Errorf("invalid stone: %q", string(s)))
+	}
+	return n
+}
+
+func (s bigStone) numDigits() int {
+	return len(s)
+}
+
+func (s bigStone) split() (stoneValue, stoneValue) {
+	half := len(s) / 2
+	left := s[:half]
+	if len(left) == 0 {
+		left = "0"
+	}
+
+	// -- Drop leading zeros from the right half.
+	right := strings.TrimLeft(string(s[half:]), "0")
+	if len(right) == 0 {
+		right = "0"
+	}
+
+	return left, bigStone(right)
+}
+
+func (s bigStone) cmp(n int) int {
+	return s.toBig().Cmp(big.NewInt(int64(n)))
+}
+
+func (s bigStone) mod(m int) int {
+	return int(new(big.Int).Mod(s.toBig(), big.NewInt(int64(m))).Int64())
+}
+
+func (s bigStone) add(n int) stoneValue {
+	return newBigStone(new(big.Int).Add(s.toBig(), big.NewInt(int64(n))))
+}
+
+func (s bigStone) mul(n int) stoneValue {
+	return newBigStone(new(big.Int).Mul(s.toBig(), big.NewInt(int64(n))))
+}
+
+func parseStone(str string, useBig bool) stoneValue {
+	// -- Digits and halves are only defined for engravings without a sign.
+	if strings.HasPrefix(str, "-") {
+		panic(fmt.Errorf("%w: %s", errNegative, str))
+	}
+
+	if useBig {
+		n, ok := new(big.Int).SetString(str, 10)
+		if !ok {
+			panic(fmt.Errorf("invalid stone: %q", str))
+		}
+		return newBigStone(n)
+	}
+
+	num, err := strconv.Atoi(str)
+	if errors.Is(err, strconv.ErrRange) {
+		panic(fmt.Errorf("%w: %s does not fit in int, rerun with -big", errOverflow, str))
+	}
+	if err != nil {
+		panic(err)
+	}
+	return stone(num)
+}
+
+const defaultRules = `
+value == 0      -> 1
+digits % 2 == 0 -> left, right
+*               -> value * 2024
+`
+
+type condition struct {
+	subject string
+	modulus int
+	op      string
+	operand int
 }
 
-func (s stone) transform() []stone {
-	// -- Rule 1.
-	if s == 0 {
-		return []stone{1}
+func parseCondition(str string) condition {
+	fields := strings.Fields(str)
+
+	var cond condition
+	switch len(fields) {
+	case 3:
+		cond = condition{fields[0], 0, fields[1], 0}
+	case 5:
+		if fields[1] != "%" {
+			panic(fmt.Errorf("invalid condition: %q", str))
+		}
+		modulus, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic(err)
+		}
+		if modulus <= 0 {
+			panic(fmt.Errorf("invalid modulus in condition: %q", str))
+		}
+		cond = condition{fields[0], modulus, fields[3], 0}
+	default:
+		panic(fmt.Errorf("invalid condition: %q", str))
+	}
+
+	if cond.subject != "value" && cond.subject != "digits" {
+		panic(fmt.Errorf("unknown subject in condition: %q", str))
+	}
+
+	switch cond.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		panic(fmt.Errorf("unknown comparison in condition: %q", str))
 	}
 
-	// -- Rule 2.
-	numDigits := stone(math.Log10(float64(s))) + 1
-	if numDigits%2 == 0 {
-		halfLen := numDigits / 2
-		base10 := powStone(10, halfLen)
-		left := s / base10
-		right := s - (left * base10)
-		return []stone{left, right}
+	operand, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		panic(err)
 	}
+	cond.operand = operand
 
-	// Rule 3.
-	return []stone{s * 2024}
+	return cond
 }
 
-type stoneLine map[stone]int
+func (c condition) matches(s stoneValue) bool {
+	var diff int
 
-func newStoneLine(r io.Reader) stoneLine {
-	stones := make(map[stone]int)
+	switch {
+	case c.subject == "digits" && c.modulus != 0:
+		diff = s.numDigits()%c.modulus - c.operand
+	case c.subject == "digits":
+		diff = s.numDigits() - c.operand
+	case c.modulus != 0:
+		diff = s.mod(c.modulus) - c.operand
+	default:
+		diff = s.cmp(c.operand)
+	}
+
+	switch c.op {
+	case "==":
+		return diff == 0
+	case "!=":
+		return diff != 0
+	case "<":
+		return diff < 0
+	case "<=":
+		return diff <= 0
+	case ">":
+		return diff > 0
+	default:
+		return diff >= 0
+	}
+}
+
+type output struct {
+	base    string
+	op      string
+	operand int
+}
+
+func parseOutput(str string) output {
+	fields := strings.Fields(str)
+
+	var out output
+	switch len(fields) {
+	case 1:
+		out = output{fields[0], "", 0}
+	case 3:
+		operand, err := strconv.Atoi(fields[2])
+		if err != nil {
+			panic(err)
+		}
+		out = output{fields[0], fields[1], operand}
+	default:
+		panic(fmt.Errorf("invalid output: %q", str))
+	}
+
+	switch out.base {
+	case "value", "left", "right":
+	default:
+		if _, ok := new(big.Int).SetString(out.base, 10); !ok {
+			panic(fmt.Errorf("unknown stone in output: %q", str))
+		}
+	}
+
+	if out.op != "" && out.op != "+" && out.op != "*" {
+		panic(fmt.Errorf("unknown operator in output: %q", str))
+	}
+
+	return out
+}
+
+func (o output) apply(s stoneValue, useBig bool) stoneValue {
+	var result stoneValue
+
+	switch o.base {
+	case "value":
+		result = s
+	case "left":
+		result, _ = s.split()
+	case "right":
+		_, result = s.split()
+	default:
+		result = parseStone(o.base, useBig)
+	}
+
+	switch o.op {
+	case "+":
+		result = result.add(o.operand)
+	case "*":
+		result = result.mul(o.operand)
+	}
+
+	if result.cmp(0) < 0 {
+		panic(fmt.Errorf("%w: rule output %s %s %d", errNegative, o.base, o.op, o.operand))
+	}
+	return result
+}
+
+type rule struct {
+	conditions []condition
+	outputs    []output
+}
+
+type ruleSet struct {
+	rules  []rule
+	useBig bool
+}
+
+func newRuleSet(r io.Reader, useBig bool) ruleSet {
+	var rules []rule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		condStr, outStr, ok := strings.Cut(line, "->")
+		if !ok {
+			panic(fmt.Errorf("rule is missing '->': %q", line))
+		}
+
+		// -- Parse conditions, "*" matches every stone.
+		var conditions []condition
+		if strings.TrimSpace(condStr) != "*" {
+			for _, str := range strings.Split(condStr, " and ") {
+				conditions = append(conditions, parseCondition(str))
+			}
+		}
+
+		// -- Parse output stones.
+		var outputs []output
+		for _, str := range strings.Split(outStr, ",") {
+			outputs = append(outputs, parseOutput(str))
+		}
+
+		rules = append(rules, rule{conditions, outputs})
+	}
+
+	return ruleSet{rules, useBig}
+}
+
+func (rs ruleSet) parseStone(str string) stoneValue {
+	return parseStone(str, rs.useBig)
+}
+
+func (rs ruleSet) transform(s stoneValue) []stoneValue {
+	// -- First matching rule wins, stones matching no rule stay as they are.
+	for _, rule := range rs.rules {
+		matched := true
+		for _, cond := range rule.conditions {
+			if !cond.matches(s) {
+				matched = false
+				break
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		stones := make([]stoneValue, 0, len(rule.outputs))
+		for _, out := range rule.outputs {
+			stones = append(stones, out.apply(s, rs.useBig))
+		}
+		return stones
+	}
+
+	return []stoneValue{s}
+}
+
+type stoneLine map[stoneValue]int
+
+func newStoneLine(r io.Reader, rs ruleSet) stoneLine {
+	stones := make(map[stoneValue]int)
 
 	scanner := bufio.NewScanner(r)
 	if !scanner.Scan() {
@@ -49,21 +399,17 @@ func newStoneLine(r io.Reader) stoneLine {
 	strs := strings.Split(line, " ")
 
 	for _, str := range strs {
-		num, err := strconv.Atoi(str)
-		if err != nil {
-			panic(err)
-		}
-		stones[stone(num)] += 1
+		stones[rs.parseStone(str)] += 1
 	}
 
 	return stones
 }
 
-func (sl *stoneLine) transform() {
-	transformed := make(map[stone]int)
+func (sl *stoneLine) transform(rs ruleSet) {
+	transformed := make(map[stoneValue]int)
 
 	for stone, count := range *sl {
-		newStones := stone.transform()
+		newStones := rs.transform(stone)
 		for _, stone := range newStones {
 			transformed[stone] += count
 		}
@@ -72,9 +418,9 @@ func (sl *stoneLine) transform() {
 	*sl = transformed
 }
 
-func (sl *stoneLine) doTransforms(times int) {
+func (sl *stoneLine) doTransforms(rs ruleSet, times int) {
 	for range times {
-		sl.transform()
+		sl.transform(rs)
 	}
 }
 
@@ -88,8 +434,490 @@ func (sl stoneLine) len() int {
 	return total
 }
 
+type blinkKey struct {
+	stone  stoneValue
+	blinks int
+}
+
+type blinkCounter struct {
+	rules   ruleSet
+	modulus *big.Int
+	memo    map[blinkKey]*big.Int
+}
+
+func newBlinkCounter(rules ruleSet, modulus int) blinkCounter {
+	var mod *big.Int
+	if modulus != 0 {
+		mod = big.NewInt(int64(modulus))
+	}
+	return blinkCounter{rules, mod, make(map[blinkKey]*big.Int)}
+}
+
+func (bc blinkCounter) count(s stoneValue, blinks int) *big.Int {
+	if blinks == 0 {
+		return big.NewInt(1)
+	}
+
+	// -- Check if cached result exists.
+	key := blinkKey{s, blinks}
+	cached, ok := bc.memo[key]
+	if ok {
+		return cached
+	}
+
+	total := new(big.Int)
+	for _, next := range bc.rules.transform(s) {
+		total.Add(total, bc.count(next, blinks-1))
+	}
+
+	if bc.modulus != nil {
+		total.Mod(total, bc.modulus)
+	}
+
+	bc.memo[key] = total
+	return total
+}
+
+func (bc blinkCounter) countLine(sl stoneLine, blinks int) *big.Int {
+	total := new(big.Int)
+
+	for stone, count := range sl {
+		stoneCount := new(big.Int).Mul(bc.count(stone, blinks), big.NewInt(int64(count)))
+		total.Add(total, stoneCount)
+	}
+
+	if bc.modulus != nil {
+		total.Mod(total, bc.modulus)
+	}
+
+	return total
+}
+
+func addMod(a uint64, b uint64, p uint64) uint64 {
+	return (a + b) % p
+}
+
+func mulMod(a uint64, b uint64, p uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, p)
+}
+
+func powMod(base uint64, exponent uint64, p uint64) uint64 {
+	result := 1 % p
+	for ; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			result = mulMod(result, base, p)
+		}
+		base = mulMod(base, base, p)
+	}
+	return result
+}
+
+func berlekampMassey(seq []uint64, p uint64) []uint64 {
+	var curr []uint64
+	var prev []uint64
+	prevDiscrepancy := uint64(1)
+	shift := 1
+
+	for n := range seq {
+		// -- How far the current recurrence is off for this term.
+		discrepancy := seq[n]
+		for index, coef := range curr {
+			discrepancy = addMod(discrepancy, p-mulMod(coef, seq[n-1-index], p), p)
+		}
+
+		if discrepancy == 0 {
+			shift += 1
+			continue
+		}
+
+		// -- Correct it using the last recurrence that failed.
+		scale := mulMod(discrepancy, powMod(prevDiscrepancy, p-2, p), p)
+		next := slices.Clone(curr)
+		for len(next) < len(prev)+shift {
+			next = append(next, 0)
+		}
+
+		next[shift-1] = addMod(next[shift-1], scale, p)
+		for index, coef := range prev {
+			next[index+shift] = addMod(next[index+shift], p-mulMod(scale, coef, p), p)
+		}
+
+		if 2*len(curr) <= n {
+			prev = curr
+			prevDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift += 1
+		}
+		curr = next
+	}
+
+	return curr
+}
+
+func polyMulMod(a []uint64, b []uint64, recurrence []uint64, p uint64) []uint64 {
+	order := len(recurrence)
+	product := make([]uint64, 2*order)
+
+	for i, x := range a {
+		if x == 0 {
+			continue
+		}
+		for j, y := range b {
+			product[i+j] = addMod(product[i+j], mulMod(x, y, p), p)
+		}
+	}
+
+	// -- Reduce using x^order = sum of recurrence[i] * x^(order-1-i).
+	for degree := len(product) - 1; degree >= order; degree -= 1 {
+		coef := product[degree]
+		if coef == 0 {
+			continue
+		}
+		for index, rec := range recurrence {
+			lower := degree - 1 - index
+			product[lower] = addMod(product[lower], mulMod(coef, rec, p), p)
+		}
+	}
+
+	return product[:order]
+}
+
+type transitions struct {
+	stones []stoneValue
+	index  map[stoneValue]int
+	next   [][]int
+}
+
+func newTransitions(rs ruleSet, roots []stoneValue) transitions {
+	t := transitions{index: make(map[stoneValue]int)}
+
+	var queue []int
+	for _, root := range roots {
+		queue = append(queue, t.add(root))
+	}
+
+	// -- Explore every stone reachable from the roots.
+	for len(queue) != 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		if t.next[curr] != nil {
+			continue
+		}
+
+		t.next[curr] = []int{}
+		for _, stone := range rs.transform(t.stones[curr]) {
+			next := t.add(stone)
+			t.next[curr] = append(t.next[curr], next)
+			queue = append(queue, next)
+		}
+	}
+
+	return t
+}
+
+func (t *transitions) add(s stoneValue) int {
+	index, ok := t.index[s]
+	if ok {
+		return index
+	}
+
+	index = len(t.stones)
+	t.index[s] = index
+	t.stones = append(t.stones, s)
+	t.next = append(t.next, nil)
+	return index
+}
+
+func (t transitions) components() [][]int {
+	var components [][]int
+	var stack []int
+
+	order := make([]int, len(t.stones))
+	lowLink := make([]int, len(t.stones))
+	onStack := make([]bool, len(t.stones))
+	counter := 0
+
+	// -- Tarjan's strongly connected components.
+	var visit func(int)
+	visit = func(curr int) {
+		counter += 1
+		order[curr] = counter
+		lowLink[curr] = counter
+		stack = append(stack, curr)
+		onStack[curr] = true
+
+		for _, next := range t.next[curr] {
+			if order[next] == 0 {
+				visit(next)
+				lowLink[curr] = min(lowLink[curr], lowLink[next])
+			} else if onStack[next] {
+				lowLink[curr] = min(lowLink[curr], order[next])
+			}
+		}
+
+		if lowLink[curr] != order[curr] {
+			return
+		}
+
+		var component []int
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+
+			if top == curr {
+				break
+			}
+		}
+		components = append(components, component)
+	}
+
+	for index := range t.stones {
+		if order[index] == 0 {
+			visit(index)
+		}
+	}
+
+	return components
+}
+
+func (t transitions) cyclic() []bool {
+	cyclic := make([]bool, len(t.stones))
+
+	for _, component := range t.components() {
+		if len(component) > 1 {
+			for _, index := range component {
+				cyclic[index] = true
+			}
+			continue
+		}
+
+		index := component[0]
+		if slices.Contains(t.next[index], index) {
+			cyclic[index] = true
+		}
+	}
+
+	return cyclic
+}
+
+func (t transitions) core() []bool {
+	// -- The core is everything reachable from a cycle, it is closed under blinking.
+	inCore := t.cyclic()
+
+	var queue []int
+	for index, isCyclic := range inCore {
+		if isCyclic {
+			queue = append(queue, index)
+		}
+	}
+
+	for len(queue) != 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		for _, next := range t.next[curr] {
+			if !inCore[next] {
+				inCore[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return inCore
+}
+
+func (t transitions) transientDepth(inCore []bool) []int {
+	// -- Stones outside the core form a DAG, measure the longest chain before the core.
+	depth := make([]int, len(t.stones))
+	done := make([]bool, len(t.stones))
+
+	var visit func(int) int
+	visit = func(curr int) int {
+		if inCore[curr] {
+			return 0
+		}
+		if done[curr] {
+			return depth[curr]
+		}
+
+		longest := 0
+		for _, next := range t.next[curr] {
+			longest = max(longest, visit(next))
+		}
+
+		depth[curr] = longest + 1
+		done[curr] = true
+		return depth[curr]
+	}
+
+	for index := range t.stones {
+		visit(index)
+	}
+
+	return depth
+}
+
+type transitionReport struct {
+	reachable  int
+	components int
+	cyclic     int
+	core       int
+	maxDepth   int
+}
+
+func (t transitions) analyze() transitionReport {
+	inCore := t.core()
+
+	report := transitionReport{
+		reachable:  len(t.stones),
+		components: len(t.components()),
+	}
+
+	for _, isCyclic := range t.cyclic() {
+		if isCyclic {
+			report.cyclic += 1
+		}
+	}
+
+	for _, isCore := range inCore {
+		if isCore {
+			report.core += 1
+		}
+	}
+
+	report.maxDepth = slices.Max(t.transientDepth(inCore))
+	return report
+}
+
+func (t transitions) sequence(roots map[stoneValue]int, length int, p uint64) []uint64 {
+	counts := make([]uint64, len(t.stones))
+	for index := range counts {
+		counts[index] = 1 % p
+	}
+
+	seq := make([]uint64, 0, length)
+	for range length {
+		total := uint64(0)
+		for stone, stoneCount := range roots {
+			stoneTotal := mulMod(counts[t.index[stone]], uint64(stoneCount)%p, p)
+			total = addMod(total, stoneTotal, p)
+		}
+		seq = append(seq, total)
+
+		// -- One sparse application of the transition matrix.
+		next := make([]uint64, len(t.stones))
+		for index, children := range t.next {
+			for _, child := range children {
+				next[index] = addMod(next[index], counts[child], p)
+			}
+		}
+		counts = next
+	}
+
+	return seq
+}
+
+func (t transitions) countMod(roots map[stoneValue]int, blinks int, p uint64) uint64 {
+	// -- The counts obey the minimal polynomial of the transition matrix,
+	// -- whose degree is at most the number of reachable stones.
+	seq := t.sequence(roots, 2*len(t.stones)+2, p)
+	if blinks < len(seq) {
+		return seq[blinks]
+	}
+
+	recurrence := berlekampMassey(seq, p)
+	if len(recurrence) == 0 {
+		return 0
+	}
+
+	// -- Raise x to the number of blinks modulo that polynomial.
+	result := make([]uint64, len(recurrence))
+	result[0] = 1 % p
+
+	power := make([]uint64, len(recurrence))
+	if len(recurrence) == 1 {
+		power[0] = recurrence[0]
+	} else {
+		power[1] = 1 % p
+	}
+
+	for exponent := blinks; exponent > 0; exponent >>= 1 {
+		if exponent&1 == 1 {
+			result = polyMulMod(result, power, recurrence, p)
+		}
+		power = polyMulMod(power, power, recurrence, p)
+	}
+
+	total := uint64(0)
+	for index, coef := range result {
+		total = addMod(total, mulMod(coef, seq[index], p), p)
+	}
+
+	return total
+}
+
 func main() {
-	stones := newStoneLine(os.Stdin)
-	stones.doTransforms(75)
-	fmt.Println(stones.len())
+	rulesPath := flag.String("rules", "", "file of blink rules, defaults to the puzzle rules")
+	useBig := flag.Bool("big", false, "use arbitrary-precision stones")
+	blinks := flag.Int("blinks", 75, "number of blinks")
+	method := flag.String("method", "histogram", "counting method: histogram, memo or matrix")
+	modulus := flag.Int("mod", 0, "count stones modulo this prime")
+	analyze := flag.Bool("analyze", false, "print how the reachable stones transition into each other")
+	flag.Parse()
+
+	if *modulus != 0 && !big.NewInt(int64(*modulus)).ProbablyPrime(20) {
+		panic(fmt.Sprintf("modulus %d is not prime", *modulus))
+	}
+	if *modulus < 0 {
+		panic("modulus must be positive")
+	}
+
+	var rulesReader io.Reader = strings.NewReader(defaultRules)
+	if len(*rulesPath) != 0 {
+		file, err := os.Open(*rulesPath)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		rulesReader = file
+	}
+
+	rules := newRuleSet(rulesReader, *useBig)
+	stones := newStoneLine(os.Stdin, rules)
+
+	if *analyze {
+		t := newTransitions(rules, slices.Collect(maps.Keys(stones)))
+		report := t.analyze()
+		fmt.Println("reachable stones:   ", report.reachable)
+		fmt.Println("components:         ", report.components)
+		fmt.Println("stones on cycles:   ", report.cyclic)
+		fmt.Println("closed core:        ", report.core)
+		fmt.Println("longest transient:  ", report.maxDepth)
+		return
+	}
+
+	switch *method {
+	case "histogram":
+		if *modulus != 0 {
+			panic("-mod needs -method memo or matrix")
+		}
+		stones.doTransforms(rules, *blinks)
+		fmt.Println(stones.len())
+	case "memo":
+		counter := newBlinkCounter(rules, *modulus)
+		fmt.Println(counter.countLine(stones, *blinks))
+	case "matrix":
+		if *modulus == 0 {
+			panic("-method matrix needs -mod")
+		}
+		t := newTransitions(rules, slices.Collect(maps.Keys(stones)))
+		fmt.Println(t.countMod(stones, *blinks, uint64(*modulus)))
+	default:
+		panic(fmt.Sprintf("unknown method: %s", *method))
+	}
 }
@@ -78,6 +78,43 @@ func (r region) price() int {
 	return r.perimeter() * len(r.coords)
 }
 
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(length int) unionFind {
+	parent := make([]int, length)
+	size := make([]int, length)
+	for index := range parent {
+		parent[index] = index
+		size[index] = 1
+	}
+	return unionFind{parent, size}
+}
+
+func (uf unionFind) find(index int) int {
+	for uf.parent[index] != index {
+		uf.parent[index] = uf.parent[uf.parent[index]]
+		index = uf.parent[index]
+	}
+	return index
+}
+
+func (uf unionFind) union(a int, b int) {
+	a = uf.find(a)
+	b = uf.find(b)
+	if a == b {
+		return
+	}
+
+	if uf.size[a] < uf.size[b] {
+		a, b = b, a
+	}
+	uf.parent[b] = a
+	uf.size[a] += uf.size[b]
+}
+
 type garden struct {
 	grid    [][]byte
 	numRows int
@@ -115,50 +152,45 @@ func (g garden) at(pos coord) byte {
 	return g.grid[pos.row][pos.col]
 }
 
-func (g garden) walkRegion(pos coord, visited *set[coord]) region {
-	plant := g.at(pos)
-	coords := newSet[coord]()
-	coords.insert(pos)
-	visited.insert(pos)
-
-	queue := []coord{pos}
-
-	for len(queue) != 0 {
-		curr := queue[0]
-		queue = queue[1:]
-
-		for _, direction := range directions {
-			next := curr.move(direction)
+func (g garden) index(pos coord) int {
+	return pos.row*g.numCols + pos.col
+}
 
-			if !g.inBounds(next) || g.at(next) != plant || visited.contains(next) {
-				continue
+func (g garden) findRegions() []region {
+	uf := newUnionFind(g.numRows * g.numCols)
+
+	// -- Join every plot to matching neighbours east and south.
+	for row := range g.numRows {
+		for col := range g.numCols {
+			pos := coord{row, col}
+
+			for _, direction := range [...]direction{EAST, SOUTH} {
+				next := pos.move(direction)
+				if g.inBounds(next) && g.at(next) == g.at(pos) {
+					uf.union(g.index(pos), g.index(next))
+				}
 			}
-
-			queue = append(queue, next)
-			coords.insert(next)
-			visited.insert(next)
 		}
 	}
 
-	return region{plant, coords}
-}
-
-func (g garden) findRegions() []region {
+	// -- Gather plots by their root, in order of first appearance.
 	var regions []region
-	visited := newSet[coord]()
-	pos := coord{0, 0}
-
-	for ; pos.row < g.numRows; pos.row += 1 {
-		for ; pos.col < g.numCols; pos.col += 1 {
-			if visited.contains(pos) {
-				continue
+	regionIndex := make(map[int]int)
+
+	for row := range g.numRows {
+		for col := range g.numCols {
+			pos := coord{row, col}
+			root := uf.find(g.index(pos))
+
+			index, ok := regionIndex[root]
+			if !ok {
+				index = len(regions)
+				regionIndex[root] = index
+				regions = append(regions, region{g.at(pos), newSet[coord]()})
 			}
 
-			region := g.walkRegion(pos, &visited)
-			regions = append(regions, region)
+			regions[index].coords.insert(pos)
 		}
-
-		pos.col = 0
 	}
 
 	return regions
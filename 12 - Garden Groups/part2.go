@@ -2,10 +2,15 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
 )
 
 type set[T comparable] map[T]struct{}
@@ -91,73 +96,417 @@ type region struct {
 	limits bounds
 }
 
+var corners = [...][2]direction{{NORTH, EAST}, {EAST, SOUTH}, {SOUTH, WEST}, {WEST, NORTH}}
+
 func (r region) countSides() int {
-	var pos coord
 	sides := 0
 
-	// -- Do vertical.
-	for pos.col = r.limits.minCol; pos.col <= r.limits.maxCol; pos.col += 1 {
-		onWestFence := false
-		onEastFence := false
-
-		for pos.row = r.limits.minRow; pos.row <= r.limits.maxRow; pos.row += 1 {
-			for _, direction := range [...]direction{WEST, EAST} {
-				var onDirFence *bool
-				switch direction {
-				case WEST:
-					onDirFence = &onWestFence
-				case EAST:
-					onDirFence = &onEastFence
-				default:
-					panic("invalid direction")
-				}
+	// -- A region has as many sides as corners, each plot checks its four.
+	for pos := range r.coords {
+		for _, corner := range corners {
+			first := r.coords.contains(pos.move(corner[0]))
+			second := r.coords.contains(pos.move(corner[1]))
+			diagonal := r.coords.contains(pos.move(corner[0]).move(corner[1]))
+
+			isOuter := !first && !second
+			isInner := first && second && !diagonal
+			if isOuter || isInner {
+				sides += 1
+			}
+		}
+	}
 
-				if r.coords.contains(pos) && !r.coords.contains(pos.move(direction)) {
-					if !*onDirFence {
-						sides += 1
-					}
-					*onDirFence = true
-				} else {
-					*onDirFence = false
-				}
+	return sides
+}
+
+func (r region) perimeter() int {
+	perimeter := 0
+
+	for curr := range r.coords {
+		for _, direction := range directions {
+			next := curr.move(direction)
+
+			if !r.coords.contains(next) {
+				perimeter += 1
+			}
+		}
+	}
+
+	return perimeter
+}
+
+func (r region) price() int {
+	return r.countSides() * len(r.coords)
+}
+
+type vertex struct {
+	row int
+	col int
+}
+
+type edge struct {
+	from vertex
+	to   vertex
+}
+
+func (e edge) heading() coord {
+	return coord{e.to.row - e.from.row, e.to.col - e.from.col}
+}
+
+func (c coord) turnRight() coord {
+	return coord{c.col, -c.row}
+}
+
+func (c coord) edge(dir direction) edge {
+	// -- Fence edges run clockwise around the cell so the region is on the right.
+	topLeft := vertex{c.row, c.col}
+	topRight := vertex{c.row, c.col + 1}
+	bottomRight := vertex{c.row + 1, c.col + 1}
+	bottomLeft := vertex{c.row + 1, c.col}
+
+	switch dir {
+	case NORTH:
+		return edge{topLeft, topRight}
+	case EAST:
+		return edge{topRight, bottomRight}
+	case SOUTH:
+		return edge{bottomRight, bottomLeft}
+	case WEST:
+		return edge{bottomLeft, topLeft}
+	default:
+		panic("invalid direction")
+	}
+}
+
+type polygon struct {
+	corners []vertex
+	cell    coord
+}
+
+func (p polygon) area() int {
+	// -- Shoelace formula, positive for outer boundaries and negative for holes.
+	twiceArea := 0
+	for index, curr := range p.corners {
+		next := p.corners[(index+1)%len(p.corners)]
+		twiceArea += curr.col*next.row - next.col*curr.row
+	}
+	return twiceArea / 2
+}
+
+func (p polygon) isHole() bool {
+	return p.area() < 0
+}
+
+func (p polygon) contains(pos coord) bool {
+	// -- Cast a ray east from the cell centre, in doubled coordinates to avoid touching corners.
+	row := 2*pos.row + 1
+	col := 2*pos.col + 1
+	inside := false
+
+	for index, curr := range p.corners {
+		next := p.corners[(index+1)%len(p.corners)]
+		if curr.col != next.col || 2*curr.col < col {
+			continue
+		}
+
+		if min(2*curr.row, 2*next.row) < row && row < max(2*curr.row, 2*next.row) {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func (r region) sortedCoords() []coord {
+	coords := make([]coord, 0, len(r.coords))
+	for pos := range r.coords {
+		coords = append(coords, pos)
+	}
+
+	slices.SortFunc(coords, func(a coord, b coord) int {
+		if a.row != b.row {
+			return a.row - b.row
+		}
+		return a.col - b.col
+	})
+
+	return coords
+}
+
+func (r region) trace() []polygon {
+	// -- Collect every fence edge, keyed by its starting corner.
+	var edges []edge
+	owners := make(map[edge]coord)
+	outgoing := make(map[vertex][]edge)
+
+	for _, pos := range r.sortedCoords() {
+		for _, direction := range directions {
+			if r.coords.contains(pos.move(direction)) {
+				continue
 			}
+
+			edge := pos.edge(direction)
+			edges = append(edges, edge)
+			owners[edge] = pos
+			outgoing[edge.from] = append(outgoing[edge.from], edge)
 		}
 	}
 
-	// -- Do horizontal.
-	for pos.row = r.limits.minRow; pos.row <= r.limits.maxRow; pos.row += 1 {
-		onNorthFence := false
-		onSouthFence := false
-
-		for pos.col = r.limits.minCol; pos.col <= r.limits.maxCol; pos.col += 1 {
-			for _, direction := range [...]direction{NORTH, SOUTH} {
-				var onDirFence *bool
-				switch direction {
-				case NORTH:
-					onDirFence = &onNorthFence
-				case SOUTH:
-					onDirFence = &onSouthFence
-				default:
-					panic("invalid direction")
+	// -- Follow edges into closed rings, turning right where the region touches itself.
+	var polygons []polygon
+	used := newSet[edge]()
+
+	for _, start := range edges {
+		if used.contains(start) {
+			continue
+		}
+
+		var ring []edge
+		for curr := start; ; {
+			used.insert(curr)
+			ring = append(ring, curr)
+
+			var next edge
+			found := false
+			for _, candidate := range outgoing[curr.to] {
+				if used.contains(candidate) && candidate != start {
+					continue
+				}
+				next = candidate
+				found = true
+				if candidate.heading() == curr.heading().turnRight() {
+					break
 				}
+			}
+
+			if !found || next == start {
+				break
+			}
+			curr = next
+		}
 
-				if r.coords.contains(pos) && !r.coords.contains(pos.move(direction)) {
-					if !*onDirFence {
-						sides += 1
-					}
-					*onDirFence = true
+		// -- Keep only the corners where the fence changes direction.
+		var corners []vertex
+		for index, curr := range ring {
+			prev := ring[(index+len(ring)-1)%len(ring)]
+			if prev.heading() != curr.heading() {
+				corners = append(corners, curr.from)
+			}
+		}
+
+		polygons = append(polygons, polygon{corners, owners[start]})
+	}
+
+	return polygons
+}
+
+func (r region) polygonSides() int {
+	sides := 0
+	for _, polygon := range r.trace() {
+		sides += len(polygon.corners)
+	}
+	return sides
+}
+
+func (r region) countHoles() int {
+	holes := 0
+	for _, polygon := range r.trace() {
+		if polygon.isHole() {
+			holes += 1
+		}
+	}
+	return holes
+}
+
+func (r region) polygonPrice() int {
+	return r.polygonSides() * len(r.coords)
+}
+
+type shape struct {
+	outer polygon
+	holes []polygon
+}
+
+func (r region) shapes() []shape {
+	var shapes []shape
+	var holes []polygon
+
+	for _, polygon := range r.trace() {
+		if polygon.isHole() {
+			holes = append(holes, polygon)
+		} else {
+			shapes = append(shapes, shape{outer: polygon})
+		}
+	}
+
+	// -- Give each hole to the outer boundary around the region cell it borders.
+	for _, hole := range holes {
+		for index := range shapes {
+			if len(shapes) == 1 || shapes[index].outer.contains(hole.cell) {
+				shapes[index].holes = append(shapes[index].holes, hole)
+				break
+			}
+		}
+	}
+
+	return shapes
+}
+
+func plantColour(plant byte) string {
+	return fmt.Sprintf("hsl(%d, 65%%, 55%%)", int(plant)*47%360)
+}
+
+func writeSVG(w io.Writer, g garden, regions []region) {
+	const cellSize = 10
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		g.numCols*cellSize, g.numRows*cellSize, g.numCols, g.numRows)
+
+	for _, region := range regions {
+		var path strings.Builder
+
+		for _, polygon := range region.trace() {
+			for index, corner := range polygon.corners {
+				if index == 0 {
+					fmt.Fprintf(&path, "M%d %d", corner.col, corner.row)
 				} else {
-					*onDirFence = false
+					fmt.Fprintf(&path, "L%d %d", corner.col, corner.row)
 				}
 			}
+			path.WriteString("Z")
 		}
+
+		fmt.Fprintf(w, "  <path d=\"%s\" fill=\"%s\" fill-rule=\"evenodd\" stroke=\"black\" stroke-width=\"0.05\"><title>%c: %d sides</title></path>\n",
+			path.String(), plantColour(region.plant), region.plant, region.polygonSides())
 	}
 
-	return sides
+	fmt.Fprintln(w, "</svg>")
 }
 
-func (r region) price() int {
-	return r.countSides() * len(r.coords)
+type geoGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type geoFeature struct {
+	Type       string         `json:"type"`
+	Geometry   geoGeometry    `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geoCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+func (p polygon) ring() [][2]int {
+	ring := make([][2]int, 0, len(p.corners)+1)
+	for _, corner := range p.corners {
+		ring = append(ring, [2]int{corner.col, corner.row})
+	}
+	return append(ring, ring[0])
+}
+
+func (s shape) rings() [][][2]int {
+	rings := [][][2]int{s.outer.ring()}
+	for _, hole := range s.holes {
+		rings = append(rings, hole.ring())
+	}
+	return rings
+}
+
+func writeGeoJSON(w io.Writer, regions []region) {
+	collection := geoCollection{Type: "FeatureCollection", Features: []geoFeature{}}
+
+	for _, region := range regions {
+		shapes := region.shapes()
+
+		geometry := geoGeometry{Type: "Polygon", Coordinates: shapes[0].rings()}
+		if len(shapes) > 1 {
+			var polygons [][][][2]int
+			for _, shape := range shapes {
+				polygons = append(polygons, shape.rings())
+			}
+			geometry = geoGeometry{Type: "MultiPolygon", Coordinates: polygons}
+		}
+
+		collection.Features = append(collection.Features, geoFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: map[string]any{
+				"plant":  string(region.plant),
+				"colour": plantColour(region.plant),
+				"area":   len(region.coords),
+				"sides":  region.polygonSides(),
+				"price":  region.polygonPrice(),
+			},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(collection); err != nil {
+		panic(err)
+	}
+}
+
+func writeReport(w io.Writer, regions []region) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "plant\tarea\tperimeter\tsides\tholes\trows\tcols\tprice\t")
+
+	for _, region := range regions {
+		fmt.Fprintf(tw, "%c\t%d\t%d\t%d\t%d\t%d-%d\t%d-%d\t%d\t\n",
+			region.plant, len(region.coords), region.perimeter(), region.countSides(),
+			region.countHoles(), region.limits.minRow, region.limits.maxRow,
+			region.limits.minCol, region.limits.maxCol, region.price())
+	}
+
+	tw.Flush()
+}
+
+func createFile(path string) *os.File {
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	return file
+}
+
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(length int) unionFind {
+	parent := make([]int, length)
+	size := make([]int, length)
+	for index := range parent {
+		parent[index] = index
+		size[index] = 1
+	}
+	return unionFind{parent, size}
+}
+
+func (uf unionFind) find(index int) int {
+	for uf.parent[index] != index {
+		uf.parent[index] = uf.parent[uf.parent[index]]
+		index = uf.parent[index]
+	}
+	return index
+}
+
+func (uf unionFind) union(a int, b int) {
+	a = uf.find(a)
+	b = uf.find(b)
+	if a == b {
+		return
+	}
+
+	if uf.size[a] < uf.size[b] {
+		a, b = b, a
+	}
+	uf.parent[b] = a
+	uf.size[a] += uf.size[b]
 }
 
 type garden struct {
@@ -197,67 +546,95 @@ func (g garden) at(pos coord) byte {
 	return g.grid[pos.row][pos.col]
 }
 
-func (g garden) walkRegion(pos coord, visited *set[coord]) region {
-	plant := g.at(pos)
-	coords := newSet[coord]()
-	coords.insert(pos)
-	limits := newBounds()
-	limits.expand(pos)
-
-	queue := []coord{pos}
-	visited.insert(pos)
+func (g garden) index(pos coord) int {
+	return pos.row*g.numCols + pos.col
+}
 
-	for len(queue) != 0 {
-		curr := queue[0]
-		queue = queue[1:]
+func (g garden) findRegions() []region {
+	uf := newUnionFind(g.numRows * g.numCols)
 
-		for _, direction := range directions {
-			next := curr.move(direction)
+	// -- Join every plot to matching neighbours east and south.
+	for row := range g.numRows {
+		for col := range g.numCols {
+			pos := coord{row, col}
 
-			if !g.inBounds(next) || g.at(next) != plant || visited.contains(next) {
-				continue
+			for _, direction := range [...]direction{EAST, SOUTH} {
+				next := pos.move(direction)
+				if g.inBounds(next) && g.at(next) == g.at(pos) {
+					uf.union(g.index(pos), g.index(next))
+				}
 			}
-
-			coords.insert(next)
-			limits.expand(next)
-			queue = append(queue, next)
-			visited.insert(next)
 		}
 	}
 
-	return region{plant, coords, limits}
-
-}
-
-func (g garden) findRegions() []region {
+	// -- Gather plots by their root, in order of first appearance.
 	var regions []region
-	visited := newSet[coord]()
-	pos := coord{0, 0}
-
-	for ; pos.row < g.numRows; pos.row += 1 {
-		for ; pos.col < g.numCols; pos.col += 1 {
-			if visited.contains(pos) {
-				continue
+	regionIndex := make(map[int]int)
+
+	for row := range g.numRows {
+		for col := range g.numCols {
+			pos := coord{row, col}
+			root := uf.find(g.index(pos))
+
+			index, ok := regionIndex[root]
+			if !ok {
+				index = len(regions)
+				regionIndex[root] = index
+				regions = append(regions, region{g.at(pos), newSet[coord](), newBounds()})
 			}
 
-			region := g.walkRegion(pos, &visited)
-			regions = append(regions, region)
+			regions[index].coords.insert(pos)
+			regions[index].limits.expand(pos)
 		}
-
-		pos.col = 0
 	}
 
 	return regions
 }
 
 func main() {
+	svgPath := flag.String("svg", "", "write region polygons as SVG to this file")
+	geoPath := flag.String("geojson", "", "write region polygons as GeoJSON to this file")
+	check := flag.Bool("check", false, "cross-check fence sides against the traced polygons")
+	report := flag.Bool("report", false, "print per-region metrics")
+	flag.Parse()
+
 	garden := newGarden(os.Stdin)
 	regions := garden.findRegions()
 
+	if *report {
+		writeReport(os.Stdout, regions)
+	}
+
+	if len(*svgPath) != 0 {
+		file := createFile(*svgPath)
+		writeSVG(file, garden, regions)
+		file.Close()
+	}
+
+	if len(*geoPath) != 0 {
+		file := createFile(*geoPath)
+		writeGeoJSON(file, regions)
+		file.Close()
+	}
+
 	total := 0
+	polygonTotal := 0
 	for _, region := range regions {
 		price := region.price()
 		total += price
+
+		if *check {
+			polygonPrice := region.polygonPrice()
+			polygonTotal += polygonPrice
+			if polygonPrice != price {
+				fmt.Fprintf(os.Stderr, "region %c: fences give %d, polygons give %d\n",
+					region.plant, price, polygonPrice)
+			}
+		}
 	}
 	fmt.Println(total)
+
+	if *check {
+		fmt.Println(polygonTotal)
+	}
 }
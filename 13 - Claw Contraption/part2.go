@@ -2,11 +2,17 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
+
+	"aoc2024/combinatorics"
 )
 
 type coord struct {
@@ -14,98 +20,668 @@ type coord struct {
 	y int
 }
 
+type button struct {
+	name  string
+	delta []int
+	cost  int
+}
+
 type clawMachine struct {
-	a coord
-	b coord
-	p coord
+	axes    []string
+	buttons []button
+	prize   []int
 }
 
-var buttonRegex = regexp.MustCompile(`Button ([AB]): X\+(\d+), Y\+(\d+)`)
-var prizeRegex = regexp.MustCompile(`Prize: X=(\d+), Y=(\d+)`)
+var buttonRegex = regexp.MustCompile(`^Button (\w+):(.*?)(?:\((\d+) tokens?\))?$`)
+var prizeRegex = regexp.MustCompile(`^Prize:(.*)$`)
+var deltaRegex = regexp.MustCompile(`([XYZ])([+-]\d+)`)
+var targetRegex = regexp.MustCompile(`([XYZ])=(-?\d+)`)
+
+var defaultCosts = map[string]int{"A": 3, "B": 1}
+
+func parseAxes(str string, axisRegex *regexp.Regexp) ([]string, []int) {
+	var axes []string
+	var values []int
+
+	for _, match := range axisRegex.FindAllStringSubmatch(str, -1) {
+		value, err := strconv.Atoi(match[2])
+		if err != nil {
+			panic(err)
+		}
+		axes = append(axes, match[1])
+		values = append(values, value)
+	}
+
+	if len(axes) == 0 {
+		panic(fmt.Sprintf("no axes in %q", str))
+	}
+
+	return axes, values
+}
 
 func newClawMachine(scanner *bufio.Scanner) *clawMachine {
-	// -- Read lines.
-	var lines [4]string
+	// -- Read lines up to the blank separator.
+	var lines []string
 
-	for index := range lines {
-		if !scanner.Scan() {
-			if index == 3 {
-				break
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	// -- Parse the prize, it is always the last line.
+	matchesP := prizeRegex.FindStringSubmatch(lines[len(lines)-1])
+	if matchesP == nil {
+		panic(fmt.Sprintf("missing prize in %q", lines[len(lines)-1]))
+	}
+	axes, prize := parseAxes(matchesP[1], targetRegex)
+
+	// -- Parse buttons.
+	var buttons []button
+
+	for _, line := range lines[:len(lines)-1] {
+		matches := buttonRegex.FindStringSubmatch(line)
+		if matches == nil {
+			panic(fmt.Sprintf("invalid button %q", line))
+		}
+
+		buttonAxes, delta := parseAxes(matches[2], deltaRegex)
+		if !slices.Equal(buttonAxes, axes) {
+			panic(fmt.Sprintf("button %s moves along %v but the prize is on %v", matches[1], buttonAxes, axes))
+		}
+
+		cost, ok := defaultCosts[matches[1]]
+		if !ok {
+			cost = 1
+		}
+		if len(matches[3]) != 0 {
+			var err error
+			cost, err = strconv.Atoi(matches[3])
+			if err != nil {
+				panic(err)
 			}
-			return nil
 		}
-		lines[index] = scanner.Text()
+
+		buttons = append(buttons, button{matches[1], delta, cost})
 	}
 
-	matchesA := buttonRegex.FindStringSubmatch(lines[0])
-	matchesB := buttonRegex.FindStringSubmatch(lines[1])
-	matchesP := prizeRegex.FindStringSubmatch(lines[2])
+	if len(buttons) == 0 {
+		panic("claw machine has no buttons")
+	}
+
+	return &clawMachine{axes, buttons, prize}
+}
+
+type clawMachineSolution struct {
+	presses []int
+	tokens  int
+}
 
-	if matchesA[1] != "A" || matchesB[1] != "B" {
-		panic("button name mismatch")
+func (cms clawMachineSolution) cost() int {
+	return cms.tokens
+}
+
+var (
+	errNotOnLine  = errors.New("prize is off the line both buttons move along")
+	errFractional = errors.New("prize needs a fractional number of presses")
+	errNegative   = errors.New("prize needs a negative number of presses")
+	errOverLimit  = errors.New("prize needs more presses than the limit")
+	errOverflow   = errors.New("presses do not fit in int")
+	errGaveUp     = errors.New("search gave up before finding the cheapest presses")
+)
+
+func floorDiv(a *big.Int, b *big.Int) *big.Int {
+	// -- Euclidean division floors for positive divisors.
+	if b.Sign() < 0 {
+		a = new(big.Int).Neg(a)
+		b = new(big.Int).Neg(b)
 	}
+	return new(big.Int).Div(a, b)
+}
 
-	if len(lines[3]) != 0 {
-		panic("non-blank separator")
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	return new(big.Int).Neg(floorDiv(new(big.Int).Neg(a), b))
+}
+
+type pressRange struct {
+	lower *big.Int
+	upper *big.Int
+	empty bool
+}
+
+func (pr *pressRange) require(coef *big.Int, rhs *big.Int) {
+	// -- Narrow the range to every t with coef * t >= rhs.
+	switch coef.Sign() {
+	case 0:
+		if rhs.Sign() > 0 {
+			pr.empty = true
+		}
+	case 1:
+		bound := ceilDiv(rhs, coef)
+		if pr.lower == nil || bound.Cmp(pr.lower) > 0 {
+			pr.lower = bound
+		}
+	case -1:
+		bound := floorDiv(rhs, coef)
+		if pr.upper == nil || bound.Cmp(pr.upper) < 0 {
+			pr.upper = bound
+		}
 	}
 
-	// -- Parse integers.
-	aX, err := strconv.Atoi(matchesA[2])
-	if err != nil {
-		panic(err)
+	if pr.lower != nil && pr.upper != nil && pr.lower.Cmp(pr.upper) > 0 {
+		pr.empty = true
 	}
-	aY, err := strconv.Atoi(matchesA[3])
-	if err != nil {
-		panic(err)
+}
+
+func extendedGCD(a *big.Int, b *big.Int) (*big.Int, *big.Int, *big.Int) {
+	x := new(big.Int)
+	y := new(big.Int)
+	g := new(big.Int).GCD(x, y, new(big.Int).Abs(a), new(big.Int).Abs(b))
+
+	// -- GCD works on magnitudes, restore the signs of the inputs.
+	if a.Sign() < 0 {
+		x.Neg(x)
 	}
-	bX, err := strconv.Atoi(matchesB[2])
-	if err != nil {
-		panic(err)
+	if b.Sign() < 0 {
+		y.Neg(y)
 	}
-	bY, err := strconv.Atoi(matchesB[3])
-	if err != nil {
-		panic(err)
+	return g, x, y
+}
+
+func (cm clawMachine) toSolution(presses []*big.Int) (clawMachineSolution, error) {
+	solution := clawMachineSolution{presses: make([]int, 0, len(presses))}
+	tokens := new(big.Int)
+
+	for index, count := range presses {
+		if !count.IsInt64() {
+			return clawMachineSolution{}, errOverflow
+		}
+		solution.presses = append(solution.presses, int(count.Int64()))
+		tokens.Add(tokens, new(big.Int).Mul(count, big.NewInt(int64(cm.buttons[index].cost))))
 	}
-	pX, err := strconv.Atoi(matchesP[1])
-	if err != nil {
-		panic(err)
+
+	if !tokens.IsInt64() {
+		return clawMachineSolution{}, errOverflow
 	}
-	pY, err := strconv.Atoi(matchesP[2])
-	if err != nil {
-		panic(err)
+	solution.tokens = int(tokens.Int64())
+
+	return solution, nil
+}
+
+func checkPresses(i *big.Int, j *big.Int, limit int) error {
+	if i.Sign() < 0 || j.Sign() < 0 {
+		return errNegative
 	}
 
-	return &clawMachine{
-		a: coord{aX, aY},
-		b: coord{bX, bY},
-		p: coord{pX, pY},
+	bigLimit := big.NewInt(int64(limit))
+	if limit > 0 && (i.Cmp(bigLimit) > 0 || j.Cmp(bigLimit) > 0) {
+		return errOverLimit
 	}
+
+	return nil
 }
 
-type clawMachineSolution struct {
-	a int
-	b int
+func (cm clawMachine) solve(offset int, limit int) (clawMachineSolution, error) {
+	// -- Two buttons on a flat board keep the closed-form fast path.
+	if len(cm.buttons) == 2 && len(cm.axes) == 2 {
+		return cm.solvePair(offset, limit)
+	}
+	return cm.solveLattice(offset, limit)
 }
 
-func (cms clawMachineSolution) cost() int {
-	return (cms.a * 3) + cms.b
+func (cm clawMachine) solvePair(offset int, limit int) (clawMachineSolution, error) {
+	a := coord{cm.buttons[0].delta[0], cm.buttons[0].delta[1]}
+	b := coord{cm.buttons[1].delta[0], cm.buttons[1].delta[1]}
+
+	aX, aY := big.NewInt(int64(a.x)), big.NewInt(int64(a.y))
+	bX, bY := big.NewInt(int64(b.x)), big.NewInt(int64(b.y))
+	pX := new(big.Int).Add(big.NewInt(int64(cm.prize[0])), big.NewInt(int64(offset)))
+	pY := new(big.Int).Add(big.NewInt(int64(cm.prize[1])), big.NewInt(int64(offset)))
+
+	det := new(big.Int).Sub(new(big.Int).Mul(aX, bY), new(big.Int).Mul(bX, aY))
+	if det.Sign() == 0 {
+		return cm.solveCollinear(a, b, pX, pY, limit)
+	}
+
+	// -- Cramer's rule.
+	i, iRem := new(big.Int).QuoRem(
+		new(big.Int).Sub(new(big.Int).Mul(bY, pX), new(big.Int).Mul(bX, pY)), det, new(big.Int))
+	j, jRem := new(big.Int).QuoRem(
+		new(big.Int).Sub(new(big.Int).Mul(aX, pY), new(big.Int).Mul(aY, pX)), det, new(big.Int))
+
+	if iRem.Sign() != 0 || jRem.Sign() != 0 {
+		return clawMachineSolution{}, errFractional
+	}
+
+	if err := checkPresses(i, j, limit); err != nil {
+		return clawMachineSolution{}, err
+	}
+
+	return cm.toSolution([]*big.Int{i, j})
 }
 
-func (cm clawMachine) solve() *clawMachineSolution {
-	a := &cm.a
-	b := &cm.b
-	p := &cm.p
+func (cm clawMachine) solveCollinear(a coord, b coord, pX *big.Int, pY *big.Int, limit int) (clawMachineSolution, error) {
+	// -- Neither button moves the claw, only a prize at the start is reachable.
+	dir := a
+	if dir == (coord{}) {
+		dir = b
+	}
+	if dir == (coord{}) {
+		if pX.Sign() != 0 || pY.Sign() != 0 {
+			return clawMachineSolution{}, errNotOnLine
+		}
+		return cm.toSolution([]*big.Int{new(big.Int), new(big.Int)})
+	}
+
+	// -- Reduce to the smallest step along the shared line.
+	dX, dY := big.NewInt(int64(dir.x)), big.NewInt(int64(dir.y))
+	divisor := new(big.Int).GCD(nil, nil, new(big.Int).Abs(dX), new(big.Int).Abs(dY))
+	dX.Quo(dX, divisor)
+	dY.Quo(dY, divisor)
 
-	det := a.x*b.y - b.x*a.y
-	i := (b.y*p.x - b.x*p.y) / det
-	j := (a.x*p.y - a.y*p.x) / det
-	pos := coord{a.x*i + b.x*j, a.y*i + b.y*j}
+	cross := new(big.Int).Sub(new(big.Int).Mul(pX, dY), new(big.Int).Mul(pY, dX))
+	if cross.Sign() != 0 {
+		return clawMachineSolution{}, errNotOnLine
+	}
 
-	if pos != *p {
-		return nil
+	steps := func(x *big.Int, y *big.Int) *big.Int {
+		if dX.Sign() != 0 {
+			return new(big.Int).Quo(x, dX)
+		}
+		return new(big.Int).Quo(y, dY)
 	}
 
-	return &clawMachineSolution{i, j}
+	kA := steps(big.NewInt(int64(a.x)), big.NewInt(int64(a.y)))
+	kB := steps(big.NewInt(int64(b.x)), big.NewInt(int64(b.y)))
+	kP := steps(pX, pY)
+
+	// -- Solve kA * i + kB * j = kP.
+	g, x, y := extendedGCD(kA, kB)
+	if g.Sign() == 0 {
+		if kP.Sign() != 0 {
+			return clawMachineSolution{}, errNotOnLine
+		}
+		return cm.toSolution([]*big.Int{new(big.Int), new(big.Int)})
+	}
+
+	scale, rem := new(big.Int).QuoRem(kP, g, new(big.Int))
+	if rem.Sign() != 0 {
+		return clawMachineSolution{}, errFractional
+	}
+
+	// -- Every solution is i = i0 + u*t, j = j0 - v*t.
+	i0 := new(big.Int).Mul(x, scale)
+	j0 := new(big.Int).Mul(y, scale)
+	u := new(big.Int).Quo(kB, g)
+	v := new(big.Int).Quo(kA, g)
+
+	var bounds pressRange
+	bounds.require(u, new(big.Int).Neg(i0))
+	bounds.require(new(big.Int).Neg(v), new(big.Int).Neg(j0))
+	if bounds.empty {
+		return clawMachineSolution{}, errNegative
+	}
+
+	if limit > 0 {
+		bigLimit := big.NewInt(int64(limit))
+		bounds.require(new(big.Int).Neg(u), new(big.Int).Sub(i0, bigLimit))
+		bounds.require(v, new(big.Int).Sub(j0, bigLimit))
+		if bounds.empty {
+			return clawMachineSolution{}, errOverLimit
+		}
+	}
+
+	// -- Cost changes linearly with t, so the cheapest press count is at an end.
+	costA := big.NewInt(int64(cm.buttons[0].cost))
+	costB := big.NewInt(int64(cm.buttons[1].cost))
+	slope := new(big.Int).Sub(new(big.Int).Mul(costA, u), new(big.Int).Mul(costB, v))
+
+	t := bounds.lower
+	if slope.Sign() < 0 || t == nil {
+		t = bounds.upper
+	}
+	if t == nil {
+		t = new(big.Int)
+	}
+
+	i := new(big.Int).Add(i0, new(big.Int).Mul(u, t))
+	j := new(big.Int).Sub(j0, new(big.Int).Mul(v, t))
+	return cm.toSolution([]*big.Int{i, j})
+}
+
+type lattice struct {
+	base  []*big.Int
+	basis [][]*big.Int
+}
+
+func (cm clawMachine) solutionLattice(offset int) (lattice, error) {
+	numAxes := len(cm.axes)
+	numButtons := len(cm.buttons)
+
+	// -- Column-style Hermite reduction: track unimodular column operations in unimod.
+	reduced := make([][]*big.Int, numAxes)
+	for row := range reduced {
+		reduced[row] = make([]*big.Int, numButtons)
+		for col := range reduced[row] {
+			reduced[row][col] = big.NewInt(int64(cm.buttons[col].delta[row]))
+		}
+	}
+
+	unimod := make([][]*big.Int, numButtons)
+	for row := range unimod {
+		unimod[row] = make([]*big.Int, numButtons)
+		for col := range unimod[row] {
+			unimod[row][col] = new(big.Int)
+		}
+		unimod[row][row].SetInt64(1)
+	}
+
+	combineColumns := func(matrix [][]*big.Int, pivot int, other int, x, y, s, t *big.Int) {
+		// -- [pivot other] = [pivot other] * [[x s] [y t]].
+		for row := range matrix {
+			p, o := matrix[row][pivot], matrix[row][other]
+			newPivot := new(big.Int).Add(new(big.Int).Mul(p, x), new(big.Int).Mul(o, y))
+			newOther := new(big.Int).Add(new(big.Int).Mul(p, s), new(big.Int).Mul(o, t))
+			matrix[row][pivot] = newPivot
+			matrix[row][other] = newOther
+		}
+	}
+
+	var pivotRows []int
+	rank := 0
+
+	for row := range numAxes {
+		if rank == numButtons {
+			break
+		}
+
+		for col := rank + 1; col < numButtons; col += 1 {
+			a, b := reduced[row][rank], reduced[row][col]
+			if b.Sign() == 0 {
+				continue
+			}
+
+			g, x, y := extendedGCD(a, b)
+			s := new(big.Int).Neg(new(big.Int).Quo(b, g))
+			t := new(big.Int).Quo(a, g)
+			combineColumns(reduced, rank, col, x, y, s, t)
+			combineColumns(unimod, rank, col, x, y, s, t)
+		}
+
+		if reduced[row][rank].Sign() != 0 {
+			pivotRows = append(pivotRows, row)
+			rank += 1
+		}
+	}
+
+	// -- Forward substitution for the pivot columns, other rows must already agree.
+	target := make([]*big.Int, numAxes)
+	for axis := range target {
+		target[axis] = new(big.Int).Add(big.NewInt(int64(cm.prize[axis])), big.NewInt(int64(offset)))
+	}
+
+	fixed := make([]*big.Int, rank)
+	pivot := 0
+
+	for row := range numAxes {
+		sum := new(big.Int)
+		for col := 0; col < pivot; col += 1 {
+			sum.Add(sum, new(big.Int).Mul(reduced[row][col], fixed[col]))
+		}
+		rest := new(big.Int).Sub(target[row], sum)
+
+		if pivot < rank && pivotRows[pivot] == row {
+			quo, rem := new(big.Int).QuoRem(rest, reduced[row][pivot], new(big.Int))
+			if rem.Sign() != 0 {
+				return lattice{}, errFractional
+			}
+			fixed[pivot] = quo
+			pivot += 1
+		} else if rest.Sign() != 0 {
+			return lattice{}, errNotOnLine
+		}
+	}
+
+	// -- Presses are base + basis * z for any integer z.
+	base := make([]*big.Int, numButtons)
+	basis := make([][]*big.Int, numButtons)
+
+	for row := range numButtons {
+		base[row] = new(big.Int)
+		for col := range rank {
+			base[row].Add(base[row], new(big.Int).Mul(unimod[row][col], fixed[col]))
+		}
+		basis[row] = unimod[row][rank:]
+	}
+
+	return lattice{base, basis}, nil
+}
+
+type constraint struct {
+	coefs []*big.Int
+	rhs   *big.Int
+}
+
+func (c constraint) holds(z []*big.Rat) bool {
+	sum := new(big.Rat)
+	for index, coef := range c.coefs {
+		sum.Add(sum, new(big.Rat).Mul(new(big.Rat).SetInt(coef), z[index]))
+	}
+	return sum.Cmp(new(big.Rat).SetInt(c.rhs)) >= 0
+}
+
+func solveSquare(rows []constraint) ([]*big.Rat, bool) {
+	size := len(rows)
+	matrix := make([][]*big.Rat, size)
+	for row := range rows {
+		matrix[row] = make([]*big.Rat, size+1)
+		for col, coef := range rows[row].coefs {
+			matrix[row][col] = new(big.Rat).SetInt(coef)
+		}
+		matrix[row][size] = new(big.Rat).SetInt(rows[row].rhs)
+	}
+
+	// -- Gauss-Jordan elimination over the rationals.
+	for col := range size {
+		pivot := -1
+		for row := col; row < size; row += 1 {
+			if matrix[row][col].Sign() != 0 {
+				pivot = row
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, false
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+
+		for row := range size {
+			if row == col || matrix[row][col].Sign() == 0 {
+				continue
+			}
+			factor := new(big.Rat).Quo(matrix[row][col], matrix[col][col])
+			for k := col; k <= size; k += 1 {
+				matrix[row][k].Sub(matrix[row][k], new(big.Rat).Mul(factor, matrix[col][k]))
+			}
+		}
+	}
+
+	solution := make([]*big.Rat, size)
+	for row := range size {
+		solution[row] = new(big.Rat).Quo(matrix[row][size], matrix[row][row])
+	}
+	return solution, true
+}
+
+func (l lattice) objective(costs []*big.Int) []*big.Int {
+	objective := make([]*big.Int, len(l.basis[0]))
+	for col := range objective {
+		objective[col] = new(big.Int)
+		for row, cost := range costs {
+			objective[col].Add(objective[col], new(big.Int).Mul(cost, l.basis[row][col]))
+		}
+	}
+	return objective
+}
+
+func relaxedMinimum(constraints []constraint, objective []*big.Int) ([]*big.Rat, *big.Rat, bool) {
+	// -- The press polyhedron has no lines, so its optimum sits on a vertex.
+	var best []*big.Rat
+	var bestValue *big.Rat
+
+	for active := range combinatorics.Combinations(constraints, len(objective)) {
+		vertex, ok := solveSquare(active)
+		if !ok {
+			continue
+		}
+
+		feasible := true
+		for _, c := range constraints {
+			if !c.holds(vertex) {
+				feasible = false
+				break
+			}
+		}
+		if !feasible {
+			continue
+		}
+
+		value := new(big.Rat)
+		for index, coef := range objective {
+			value.Add(value, new(big.Rat).Mul(new(big.Rat).SetInt(coef), vertex[index]))
+		}
+
+		if bestValue == nil || value.Cmp(bestValue) < 0 {
+			best = vertex
+			bestValue = value
+		}
+	}
+
+	return best, bestValue, best != nil
+}
+
+const maxSearchNodes = 100_000
+
+func (cm clawMachine) solveLattice(offset int, limit int) (clawMachineSolution, error) {
+	l, err := cm.solutionLattice(offset)
+	if err != nil {
+		return clawMachineSolution{}, err
+	}
+
+	freeDims := len(l.basis[0])
+	costs := make([]*big.Int, len(cm.buttons))
+	for index, button := range cm.buttons {
+		costs[index] = big.NewInt(int64(button.cost))
+	}
+
+	pressesAt := func(z []*big.Int) []*big.Int {
+		presses := make([]*big.Int, len(l.base))
+		for row := range presses {
+			presses[row] = new(big.Int).Set(l.base[row])
+			for col, step := range z {
+				presses[row].Add(presses[row], new(big.Int).Mul(l.basis[row][col], step))
+			}
+		}
+		return presses
+	}
+
+	// -- A unique solution only needs its bounds checked.
+	if freeDims == 0 {
+		presses := pressesAt(nil)
+		for _, count := range presses {
+			if count.Sign() < 0 {
+				return clawMachineSolution{}, errNegative
+			}
+			if limit > 0 && count.Cmp(big.NewInt(int64(limit))) > 0 {
+				return clawMachineSolution{}, errOverLimit
+			}
+		}
+		return cm.toSolution(presses)
+	}
+
+	// -- Every button needs 0 <= base + basis * z (<= limit).
+	var constraints []constraint
+	for row := range l.base {
+		constraints = append(constraints, constraint{l.basis[row], new(big.Int).Neg(l.base[row])})
+	}
+
+	objective := l.objective(costs)
+	if _, _, ok := relaxedMinimum(constraints, objective); !ok {
+		return clawMachineSolution{}, errNegative
+	}
+
+	if limit > 0 {
+		for row := range l.base {
+			coefs := make([]*big.Int, freeDims)
+			for col := range coefs {
+				coefs[col] = new(big.Int).Neg(l.basis[row][col])
+			}
+			rhs := new(big.Int).Sub(l.base[row], big.NewInt(int64(limit)))
+			constraints = append(constraints, constraint{coefs, rhs})
+		}
+
+		if _, _, ok := relaxedMinimum(constraints, objective); !ok {
+			return clawMachineSolution{}, errOverLimit
+		}
+	}
+
+	// -- Branch and bound on the free lattice coordinates.
+	var best []*big.Int
+	var bestValue *big.Rat
+	pending := [][]constraint{constraints}
+
+	for nodes := 0; len(pending) != 0; nodes += 1 {
+		if nodes == maxSearchNodes {
+			return clawMachineSolution{}, errGaveUp
+		}
+
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		vertex, value, ok := relaxedMinimum(node, objective)
+		if !ok || (bestValue != nil && value.Cmp(bestValue) >= 0) {
+			continue
+		}
+
+		fractional := slices.IndexFunc(vertex, func(r *big.Rat) bool { return !r.IsInt() })
+		if fractional < 0 {
+			best = make([]*big.Int, freeDims)
+			for index, r := range vertex {
+				best[index] = new(big.Int).Set(r.Num())
+			}
+			bestValue = value
+			continue
+		}
+
+		// -- Split into z <= floor and z >= ceil.
+		floor := floorDiv(vertex[fractional].Num(), vertex[fractional].Denom())
+		ceil := new(big.Int).Add(floor, big.NewInt(1))
+
+		below := make([]*big.Int, freeDims)
+		above := make([]*big.Int, freeDims)
+		for index := range freeDims {
+			below[index] = new(big.Int)
+			above[index] = new(big.Int)
+		}
+		below[fractional].SetInt64(-1)
+		above[fractional].SetInt64(1)
+
+		pending = append(pending,
+			append(slices.Clone(node), constraint{below, new(big.Int).Neg(floor)}),
+			append(slices.Clone(node), constraint{above, ceil}))
+	}
+
+	if best == nil {
+		return clawMachineSolution{}, errFractional
+	}
+
+	return cm.toSolution(pressesAt(best))
 }
 
 func parseClawMachines(r io.Reader) []clawMachine {
@@ -127,16 +703,20 @@ func parseClawMachines(r io.Reader) []clawMachine {
 const offset = 10_000_000_000_000
 
 func main() {
+	limit := flag.Int("limit", 0, "maximum presses per button, 0 for no limit")
+	why := flag.Bool("why", false, "explain why machines have no reachable prize")
+	flag.Parse()
+
 	clawMachines := parseClawMachines(os.Stdin)
 	total := 0
 
-	for _, cm := range clawMachines {
-		cm.p.x += offset
-		cm.p.y += offset
-
-		solution := cm.solve()
+	for index, cm := range clawMachines {
+		solution, err := cm.solve(offset, *limit)
 
-		if solution == nil {
+		if err != nil {
+			if *why {
+				fmt.Printf("machine %d: %v\n", index+1, err)
+			}
 			continue
 		}
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -62,21 +63,43 @@ type bathroom struct {
 	robots map[coord][]coord
 }
 
+var regexSize = regexp.MustCompile(`^\s*(\d+)\s*x\s*(\d+)\s*$`)
+
 func newBathroom(r io.Reader, width int, height int) (b bathroom) {
-	b.rowMax = height
-	b.colMax = width
-	b.rowMid = height / 2
-	b.colMid = width / 2
 	b.robots = make(map[coord][]coord)
 
 	scanner := bufio.NewScanner(r)
 
 	for scanner.Scan() {
 		line := scanner.Text()
+
+		// -- An optional "WIDTHxHEIGHT" header sizes the room unless a flag already did.
+		if matches := regexSize.FindStringSubmatch(line); matches != nil {
+			if width == 0 {
+				width, _ = strconv.Atoi(matches[1])
+			}
+			if height == 0 {
+				height, _ = strconv.Atoi(matches[2])
+			}
+			continue
+		}
+
 		pos, vel := newRobot(line)
 		b.robots[pos] = append(b.robots[pos], vel)
 	}
 
+	if width == 0 {
+		width = WIDTH
+	}
+	if height == 0 {
+		height = HEIGHT
+	}
+
+	b.rowMax = height
+	b.colMax = width
+	b.rowMid = height / 2
+	b.colMid = width / 2
+
 	return b
 }
 
@@ -100,6 +123,24 @@ func (b *bathroom) tick() {
 	b.robots = newRobots
 }
 
+func (b bathroom) advance(t int) bathroom {
+	moved := b
+	moved.robots = make(map[coord][]coord)
+
+	// -- Each axis repeats with its own size, so only t modulo it matters.
+	for pos, vels := range b.robots {
+		for _, vel := range vels {
+			newPos := coord{
+				row: pyModulo(pos.row+vel.row*pyModulo(t, b.rowMax), b.rowMax),
+				col: pyModulo(pos.col+vel.col*pyModulo(t, b.colMax), b.colMax),
+			}
+			moved.robots[newPos] = append(moved.robots[newPos], vel)
+		}
+	}
+
+	return moved
+}
+
 type quadrant uint8
 
 const (
@@ -153,9 +194,11 @@ const WIDTH = 101
 const HEIGHT = 103
 
 func main() {
-	b := newBathroom(os.Stdin, WIDTH, HEIGHT)
-	for range 100 {
-		b.tick()
-	}
+	width := flag.Int("width", 0, "room width (default: input header or 101)")
+	height := flag.Int("height", 0, "room height (default: input header or 103)")
+	flag.Parse()
+
+	b := newBathroom(os.Stdin, *width, *height)
+	b = b.advance(100)
 	fmt.Println(b.score())
 }
@@ -2,12 +2,22 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
 	"io"
 	"math"
+	"math/rand/v2"
 	"os"
+	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 func pyModulo(numerator int, denominator int) int {
@@ -63,21 +73,43 @@ type bathroom struct {
 	robots map[coord][]coord
 }
 
+var regexSize = regexp.MustCompile(`^\s*(\d+)\s*x\s*(\d+)\s*$`)
+
 func newBathroom(r io.Reader, width int, height int) (b bathroom) {
-	b.rowMax = height
-	b.colMax = width
-	b.rowMid = height / 2
-	b.colMid = width / 2
 	b.robots = make(map[coord][]coord)
 
 	scanner := bufio.NewScanner(r)
 
 	for scanner.Scan() {
 		line := scanner.Text()
+
+		// -- An optional "WIDTHxHEIGHT" header sizes the room unless a flag already did.
+		if matches := regexSize.FindStringSubmatch(line); matches != nil {
+			if width == 0 {
+				width, _ = strconv.Atoi(matches[1])
+			}
+			if height == 0 {
+				height, _ = strconv.Atoi(matches[2])
+			}
+			continue
+		}
+
 		pos, vel := newRobot(line)
 		b.robots[pos] = append(b.robots[pos], vel)
 	}
 
+	if width == 0 {
+		width = WIDTH
+	}
+	if height == 0 {
+		height = HEIGHT
+	}
+
+	b.rowMax = height
+	b.colMax = width
+	b.rowMid = height / 2
+	b.colMid = width / 2
+
 	return b
 }
 
@@ -101,13 +133,52 @@ func (b *bathroom) tick() {
 	b.robots = newRobots
 }
 
+func (b bathroom) advance(t int) bathroom {
+	moved := b
+	moved.robots = make(map[coord][]coord)
+
+	// -- Each axis repeats with its own size, so only t modulo it matters.
+	for pos, vels := range b.robots {
+		for _, vel := range vels {
+			newPos := coord{
+				row: pyModulo(pos.row+vel.row*pyModulo(t, b.rowMax), b.rowMax),
+				col: pyModulo(pos.col+vel.col*pyModulo(t, b.colMax), b.colMax),
+			}
+			moved.robots[newPos] = append(moved.robots[newPos], vel)
+		}
+	}
+
+	return moved
+}
+
+type detector interface {
+	isChristmasTree(b bathroom) bool
+}
 
-func (b bathroom) isChristmasTree() bool {
-	const TEST_DEPTH = 4
+func newDetector(name string) detector {
+	switch name {
+	case "triangle":
+		return triangleDetector{depth: 4}
+	case "entropy":
+		return entropyDetector{maxRatio: 0.8}
+	case "component":
+		return componentDetector{minShare: 0.2}
+	case "unique":
+		return uniqueDetector{}
+	default:
+		panic(fmt.Sprintf("unknown detector %q", name))
+	}
+}
 
+// -- Tree tip: some robot has a filled triangle of robots below it.
+type triangleDetector struct {
+	depth int
+}
+
+func (d triangleDetector) isChristmasTree(b bathroom) bool {
 eachRobot:
 	for pos := range b.robots {
-		for depth := range TEST_DEPTH {
+		for depth := range d.depth {
 			row := pos.row + depth
 			r := coord{row, pos.col + depth}
 			l := coord{row, pos.col - depth}
@@ -123,16 +194,366 @@ eachRobot:
 	return false
 }
 
+// -- Low entropy: the room compresses far better than its cells in scrambled order.
+type entropyDetector struct {
+	maxRatio float64
+}
+
+func compressedSize(cells []byte) int {
+	var buf bytes.Buffer
+
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := w.Write(cells); err != nil {
+		panic(err)
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+
+	return buf.Len()
+}
+
+func (d entropyDetector) isChristmasTree(b bathroom) bool {
+	cells := make([]byte, b.rowMax*b.colMax)
+	for index := range cells {
+		cells[index] = '.'
+	}
+	for pos := range b.robots {
+		cells[pos.row*b.colMax+pos.col] = '#'
+	}
+
+	// -- Same robots, no shape: a fixed shuffle of the cells is the baseline.
+	shuffled := make([]byte, len(cells))
+	for index, target := range rand.New(rand.NewPCG(1, 2)).Perm(len(cells)) {
+		shuffled[target] = cells[index]
+	}
+
+	ratio := float64(compressedSize(cells)) / float64(compressedSize(shuffled))
+	return ratio < d.maxRatio
+}
+
+// -- One blob: a connected group holds a large share of the occupied cells.
+type componentDetector struct {
+	minShare float64
+}
+
+func (d componentDetector) isChristmasTree(b bathroom) bool {
+	seen := make(map[coord]bool, len(b.robots))
+	largest := 0
+
+	for start := range b.robots {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+
+		size := 0
+		queue := []coord{start}
+		for len(queue) != 0 {
+			pos := queue[0]
+			queue = queue[1:]
+			size += 1
+
+			for _, delta := range []coord{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				next := pos.add(delta)
+				if _, ok := b.robots[next]; ok && !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		largest = max(largest, size)
+	}
+
+	return float64(largest) >= d.minShare*float64(len(b.robots))
+}
+
+// -- Arranged picture: every robot stands on its own cell.
+type uniqueDetector struct{}
+
+func (d uniqueDetector) isChristmasTree(b bathroom) bool {
+	for _, vels := range b.robots {
+		if len(vels) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func extendedGCD(a int, b int) (int, int, int) {
+	if b == 0 {
+		return a, 1, 0
+	}
+	g, x, y := extendedGCD(b, a%b)
+	return g, y, x - (a/b)*y
+}
+
+func chineseRemainder(r1 int, m1 int, r2 int, m2 int) (int, int, bool) {
+	g, x, _ := extendedGCD(m1, m2)
+	if (r2-r1)%g != 0 {
+		return 0, 0, false
+	}
+
+	lcm := m1 / g * m2
+	t := r1 + m1*pyModulo((r2-r1)/g*x, m2/g)
+	return pyModulo(t, lcm), lcm, true
+}
+
+func (b bathroom) quietestTime(period int, axis func(coord) int) int {
+	bestTime := 0
+	bestVariance := math.Inf(1)
+
+	// -- Robots bunch up along an axis when they draw the tree.
+	for t := range period {
+		sum := 0.0
+		sumSquares := 0.0
+		count := 0.0
+
+		for pos, vels := range b.robots {
+			for _, vel := range vels {
+				value := float64(pyModulo(axis(pos)+axis(vel)*t, period))
+				sum += value
+				sumSquares += value * value
+				count += 1
+			}
+		}
+
+		mean := sum / count
+		variance := sumSquares/count - mean*mean
+		if variance < bestVariance {
+			bestTime = t
+			bestVariance = variance
+		}
+	}
+
+	return bestTime
+}
+
+func (b bathroom) findTree(d detector) (int, bool) {
+	colTime := b.quietestTime(b.colMax, func(c coord) int { return c.col })
+	rowTime := b.quietestTime(b.rowMax, func(c coord) int { return c.row })
+
+	// -- Both axes are at their quietest together once per period.
+	t, period, ok := chineseRemainder(colTime, b.colMax, rowTime, b.rowMax)
+	if ok {
+		if t == 0 {
+			t = period
+		}
+		if d.isChristmasTree(b.advance(t)) {
+			return t, true
+		}
+	} else {
+		period = b.colMax * b.rowMax
+	}
+
+	// -- Otherwise check every time in one full period.
+	curr := b
+	for t := 1; t <= period; t += 1 {
+		curr.tick()
+		if d.isChristmasTree(curr) {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
+var framePalette = color.Palette{
+	color.RGBA{0x0f, 0x0f, 0x23, 0xff},
+	color.RGBA{0x00, 0xcc, 0x00, 0xff},
+}
+
+func (b bathroom) frame(scale int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, b.colMax*scale, b.rowMax*scale), framePalette)
+
+	for pos := range b.robots {
+		for row := pos.row * scale; row < (pos.row+1)*scale; row += 1 {
+			for col := pos.col * scale; col < (pos.col+1)*scale; col += 1 {
+				img.SetColorIndex(col, row, 1)
+			}
+		}
+	}
+
+	return img
+}
+
+func writePNG(path string, b bathroom, scale int) {
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, b.frame(scale)); err != nil {
+		panic(err)
+	}
+}
+
+func writeGIF(path string, b bathroom, from int, to int, scale int, delay int) {
+	var anim gif.GIF
+
+	for t := from; t <= to; t += 1 {
+		anim.Image = append(anim.Image, b.advance(t).frame(scale))
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	if err := gif.EncodeAll(file, &anim); err != nil {
+		panic(err)
+	}
+}
+
+func (b bathroom) render() string {
+	var sb strings.Builder
+
+	// -- Half blocks pack two grid rows into each terminal line.
+	for row := 0; row < b.rowMax; row += 2 {
+		for col := range b.colMax {
+			_, top := b.robots[coord{row, col}]
+			_, bottom := b.robots[coord{row + 1, col}]
+
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+func stty(tty *os.File, args ...string) string {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+
+	out, err := cmd.Output()
+	if err != nil {
+		panic(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func browse(b bathroom, start int) {
+	// -- Robots arrive on stdin, so keys come from the terminal itself.
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		panic(err)
+	}
+	defer tty.Close()
+
+	saved := stty(tty, "-g")
+	stty(tty, "cbreak", "-echo")
+	defer stty(tty, saved)
+
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[?25h")
+
+	keys := bufio.NewReader(tty)
+	t := start
+
+	for {
+		fmt.Print("\x1b[H\x1b[2J")
+		fmt.Print(b.advance(t).render())
+		fmt.Printf("t=%d  [l/h] ±1  [L/H] ±%d  [j/k] ±%d  [q] quit", t, b.colMax, b.rowMax)
+
+		key, err := keys.ReadByte()
+		if err != nil {
+			panic(err)
+		}
+
+		// -- Arrow keys arrive as ESC [ A-D.
+		if key == '\x1b' {
+			if next, _ := keys.ReadByte(); next == '[' {
+				arrow, _ := keys.ReadByte()
+				key = map[byte]byte{'A': 'k', 'B': 'j', 'C': 'l', 'D': 'h'}[arrow]
+			}
+		}
+
+		switch key {
+		case 'l':
+			t += 1
+		case 'h':
+			t -= 1
+		case 'L':
+			t += b.colMax
+		case 'H':
+			t -= b.colMax
+		case 'j':
+			t += b.rowMax
+		case 'k':
+			t -= b.rowMax
+		case 'q':
+			fmt.Println()
+			return
+		}
+
+		if t < 0 {
+			t = 0
+		}
+	}
+}
+
 const WIDTH = 101
 const HEIGHT = 103
 
 func main() {
-	b := newBathroom(os.Stdin, WIDTH, HEIGHT)
-	for n := range math.MaxInt64 {
-		b.tick()
-		if b.isChristmasTree() {
-			fmt.Println(n + 1)
-			return
-		}
+	pngPath := flag.String("png", "", "write the room at -tick to a PNG file")
+	gifPath := flag.String("gif", "", "write ticks -from to -to as an animated GIF")
+	doBrowse := flag.Bool("browse", false, "step through ticks in the terminal")
+	tick := flag.Int("tick", -1, "tick to render or start browsing at (default: the tree)")
+	from := flag.Int("from", 0, "first GIF tick")
+	to := flag.Int("to", -1, "last GIF tick (default: one full period)")
+	scale := flag.Int("scale", 4, "pixels per cell in images")
+	delay := flag.Int("delay", 10, "GIF frame delay in hundredths of a second")
+	width := flag.Int("width", 0, "room width (default: input header or 101)")
+	height := flag.Int("height", 0, "room height (default: input header or 103)")
+	detectorName := flag.String("detector", "triangle", "tree detector: triangle, entropy, component or unique")
+	flag.Parse()
+
+	b := newBathroom(os.Stdin, *width, *height)
+	d := newDetector(*detectorName)
+
+	if *to < 0 {
+		*to = b.colMax*b.rowMax - 1
+	}
+
+	if len(*gifPath) != 0 {
+		writeGIF(*gifPath, b, *from, *to, *scale, *delay)
+	}
+
+	t, ok := *tick, *tick >= 0
+	if !ok {
+		t, ok = b.findTree(d)
+	}
+
+	if len(*pngPath) != 0 {
+		writePNG(*pngPath, b.advance(t), *scale)
+	}
+
+	if *doBrowse {
+		browse(b, t)
+		return
+	}
+
+	if !ok {
+		fmt.Println("none")
+		return
 	}
+	fmt.Println(t)
 }
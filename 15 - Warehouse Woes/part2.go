@@ -2,195 +2,149 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"slices"
-)
+	"os/exec"
+	"strings"
 
-type coord struct {
-	row int
-	col int
-}
+	"aoc2024/warehouse"
+)
 
-var directions = map[byte]coord{
-	'^': {-1, 0},
-	'v': {+1, 0},
-	'>': {0, +1},
-	'<': {0, -1},
-}
+func stty(tty *os.File, args ...string) string {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
 
-func parseDirections(scanner *bufio.Scanner) (dirs []byte) {
-	for scanner.Scan() {
-		line := scanner.Text()
-		for i := range line {
-			dirs = append(dirs, line[i])
-		}
+	out, err := cmd.Output()
+	if err != nil {
+		panic(err)
 	}
-
-	return dirs
+	return strings.TrimSpace(string(out))
 }
 
-func (c coord) toward(dir byte) coord {
-	delta := directions[dir]
-	c.row += delta.row
-	c.col += delta.col
-	return c
-}
+func saveMoves(path string, mapText string, wh *warehouse.Warehouse) {
+	var sb strings.Builder
+	sb.WriteString(mapText)
+	sb.WriteString("\n\n")
 
-func (c *coord) move(dir byte) {
-	delta := directions[dir]
-	c.row += delta.row
-	c.col += delta.col
-}
-
-type box [2]coord
-
-func newBox(pos coord) (b box) {
-	b[0], b[1] = pos, pos
-	b[1].col += 1
-	return b
-}
-
-func (b box) toward(dir byte) box {
-	b[0].move(dir)
-	b[1].move(dir)
-	return b
-}
+	// -- Same layout as the puzzle input: the map, a blank line, then wrapped moves.
+	const LINE_LENGTH = 1000
 
-func (b box) score() int {
-	return (b[0].row * 100) + b[0].col
-}
+	for index, m := range wh.History() {
+		sb.WriteByte(m.Dir)
+		if (index+1)%LINE_LENGTH == 0 {
+			sb.WriteByte('\n')
+		}
+	}
+	if len(wh.History())%LINE_LENGTH != 0 {
+		sb.WriteByte('\n')
+	}
 
-func (b box) collidePos(pos coord) bool {
-	return b[0] == pos ||
-		b[1] == pos
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		panic(err)
+	}
 }
 
-func (b box) collideBox(other box) bool {
-	return b[0] == other[0] ||
-		b[0] == other[1] ||
-		b[1] == other[0] ||
-		b[1] == other[1]
-}
+func play(wh *warehouse.Warehouse, mapText string, savePath string) {
+	// -- The map arrives on stdin, so keys come from the terminal itself.
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		panic(err)
+	}
+	defer tty.Close()
 
-type warehouse struct {
-	walls  []coord
-	boxes  []box
-	robot  coord
-	maxRow int
-	maxCol int
-}
+	saved := stty(tty, "-g")
+	stty(tty, "cbreak", "-echo")
+	defer stty(tty, saved)
 
-func newWarehouse(scanner *bufio.Scanner) (w warehouse) {
-	row := 0
+	keys := bufio.NewReader(tty)
+	status := ""
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	for {
+		fmt.Print("\x1b[H\x1b[2J")
+		fmt.Println(wh)
+		fmt.Printf("score %d  moves %d  %s\n", wh.Score(), len(wh.History()), status)
+		fmt.Print("[arrows or ^v<>] move  [u] undo  [r] redo  [s] save  [q] quit")
+		status = ""
 
-		if len(line) == 0 {
-			break
+		key, err := keys.ReadByte()
+		if err != nil {
+			panic(err)
 		}
 
-		w.maxCol = len(line)
-
-		for col, ch := range line {
-			realCol := col * 2
-
-			switch ch {
-			case '#':
-				w.walls = append(w.walls, coord{row, realCol})
-				w.walls = append(w.walls, coord{row, realCol + 1})
-			case 'O':
-				w.boxes = append(w.boxes, newBox(coord{row, realCol}))
-			case '@':
-				w.robot.row, w.robot.col = row, realCol
-			case '.':
-				continue
-			default:
-				panic("invalid warehouse character")
+		// -- Arrow keys arrive as ESC [ A-D.
+		if key == '\x1b' {
+			if next, _ := keys.ReadByte(); next == '[' {
+				arrow, _ := keys.ReadByte()
+				key = map[byte]byte{'A': '^', 'B': 'v', 'C': '>', 'D': '<'}[arrow]
 			}
 		}
 
-		row += 1
-	}
-
-	w.maxRow = row
-	w.maxCol *= 2
-
-	return w
-}
-
-func (w *warehouse) moveBox(boxIndices []int, dir byte) bool {
-	if len(boxIndices) == 0 {
-		return true
-	}
-
-	altWh := *w
-
-	currIndex := boxIndices[0]
-	boxIndices = boxIndices[1:]
-	next := altWh.boxes[currIndex].toward(dir)
-
-	// -- Impossible due to wall collision.
-	for _, wall := range altWh.walls {
-		if next.collidePos(wall) {
-			return false
-		}
-	}
-
-	// -- Find collisions.
-	for index, bb := range altWh.boxes {
-		if index == currIndex {
-			continue
-		} else if next.collideBox(bb) {
-			boxIndices = append(boxIndices, index)
+		switch key {
+		case '^', 'v', '<', '>':
+			if wh.Step(key).Blocked {
+				status = "blocked"
+			}
+		case 'u':
+			if !wh.Undo() {
+				status = "nothing to undo"
+			}
+		case 'r':
+			if !wh.Redo() {
+				status = "nothing to redo"
+			}
+		case 's':
+			saveMoves(savePath, mapText, wh)
+			status = "saved to " + savePath
+		case 'q':
+			fmt.Println()
+			return
 		}
 	}
+}
 
-	// -- Add collisions to queue.
-	if !altWh.moveBox(boxIndices, dir) {
-		return false
+func main() {
+	width := flag.Int("width", 2, "box width in cells")
+	replay := flag.Int("replay", -1, "show the board after move N")
+	doPlay := flag.Bool("play", false, "drive the robot from the terminal, starting after -replay moves")
+	savePath := flag.String("save", "moves.txt", "where play mode saves the map and recorded moves")
+	flag.Parse()
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		panic(err)
 	}
 
-	altWh.boxes[currIndex] = next
-	w.boxes = altWh.boxes
-
-	return true
-}
-
-func (w *warehouse) moveRobot(dir byte) {
-	next := w.robot.toward(dir)
+	s := bufio.NewScanner(bytes.NewReader(input))
+	wh := warehouse.Parse(s, *width)
+	dirs := warehouse.ParseMoves(s)
 
-	if slices.Contains(w.walls, next) {
+	if *doPlay {
+		mapText, _, _ := strings.Cut(strings.ReplaceAll(string(input), "\r\n", "\n"), "\n\n")
+		wh.Seek(dirs, *replay)
+		play(wh, strings.TrimRight(mapText, "\n"), *savePath)
 		return
 	}
 
-	boxIndex := slices.IndexFunc(w.boxes, func(bb box) bool {
-		return bb.collidePos(next)
-	})
+	if *replay >= 0 {
+		wh.Seek(dirs, *replay)
 
-	if boxIndex != -1 {
-		if !w.moveBox([]int{boxIndex}, dir) {
-			return
+		history := wh.History()
+		if n := len(history); n != 0 {
+			last := history[n-1]
+			fmt.Printf("move %d/%d: %c pushed %d box(es)\n", n, len(dirs), last.Dir, len(last.Pushed))
+		} else {
+			fmt.Printf("move 0/%d\n", len(dirs))
 		}
+		fmt.Println(wh)
+		fmt.Println(wh.Score())
+		return
 	}
 
-	w.robot = next
-}
-
-func (w *warehouse) score() (score int) {
-	for _, b := range w.boxes {
-		score += b.score()
-	}
-	return score
-}
-
-func main() {
-	s := bufio.NewScanner(os.Stdin)
-	wh := newWarehouse(s)
-	dirs := parseDirections(s)
 	for _, dir := range dirs {
-		wh.moveRobot(dir)
+		wh.Step(dir)
 	}
-	fmt.Println(wh.score())
+	fmt.Println(wh.Score())
 }
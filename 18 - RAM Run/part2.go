@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
-	"maps"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type set[T comparable] map[T]struct{}
@@ -38,14 +40,16 @@ var directions = map[byte]coord{
 }
 
 type memorySpace struct {
-	size  int
-	bytes []coord
+	size   int
+	bytes  []coord
+	fallen map[coord]int
 }
 
 var byteRegex = regexp.MustCompile(`(\d+),(\d+)`)
 
 func newMemorySpace(size int, r io.Reader) (ms memorySpace) {
 	ms.size = size
+	ms.fallen = make(map[coord]int)
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -64,7 +68,12 @@ func newMemorySpace(size int, r io.Reader) (ms memorySpace) {
 			panic("invalid byte row")
 		}
 
-		ms.bytes = append(ms.bytes, coord{row, col})
+		// -- Only the first byte to land on a cell matters.
+		pos := coord{row, col}
+		if _, ok := ms.fallen[pos]; !ok {
+			ms.fallen[pos] = len(ms.bytes)
+		}
+		ms.bytes = append(ms.bytes, pos)
 	}
 
 	return ms
@@ -77,94 +86,214 @@ func (ms memorySpace) inBounds(pos coord) bool {
 		pos.col <= ms.size
 }
 
-type elf struct {
-	pos     coord
-	time    int
-	visited set[coord]
+func (ms memorySpace) isBlocked(pos coord, time int) bool {
+	fallTime, ok := ms.fallen[pos]
+	return ok && fallTime < time
 }
 
-func (e elf) isBlocked(bytes []coord) bool {
-	for _, b := range bytes[:e.time] {
-		if b == e.pos {
-			return true
-		}
+func (ms memorySpace) shortestPath(time int) []coord {
+	start := coord{0, 0}
+	exit := coord{ms.size, ms.size}
+	if ms.isBlocked(start, time) {
+		return nil
 	}
 
-	return false
-}
+	// -- One shared parent map replaces a visited set per walker.
+	parent := map[coord]coord{start: start}
+	open := []coord{start}
+
+	for len(open) != 0 {
+		curr := open[0]
+		open = open[1:]
 
-func (e elf) nextElves(ms memorySpace) (elves []elf) {
-	e.visited.insert(e.pos)
+		// -- Found exit, walk back to the start.
+		if curr == exit {
+			path := []coord{curr}
+			for curr != start {
+				curr = parent[curr]
+				path = append(path, curr)
+			}
+			slices.Reverse(path)
+			return path
+		}
 
-	for _, delta := range directions {
-		next := e
-		next.pos.row += delta.row
-		next.pos.col += delta.col
-		next.visited = maps.Clone(e.visited)
-		if !e.visited.contains(next.pos) && ms.inBounds(next.pos) {
-			elves = append(elves, next)
+		// -- Try next positions.
+		for _, delta := range directions {
+			next := coord{curr.row + delta.row, curr.col + delta.col}
+			if _, seen := parent[next]; seen || !ms.inBounds(next) || ms.isBlocked(next, time) {
+				continue
+			}
+
+			parent[next] = curr
+			open = append(open, next)
 		}
 	}
 
-	return elves
+	return nil
 }
 
 func (ms memorySpace) minStepsToExit(time int) int {
-	var open []elf
-	visited := newSet[coord]()
-
-	var start elf
-	start.time = time
-	start.visited = newSet[coord]()
-	open = append(open, start)
+	return len(ms.shortestPath(time)) - 1
+}
 
-	for len(open) != 0 {
-		curr := open[0]
-		open = open[1:]
+func (ms memorySpace) stepsOverTime() []int {
+	steps := make([]int, len(ms.bytes)+1)
+	path := ms.shortestPath(0)
+	onPath := set[coord]{}
+	for _, pos := range path {
+		onPath.insert(pos)
+	}
 
-		// -- Don't revisit positions.
-		if visited.contains(curr.pos) {
-			continue
+	// -- A route stays shortest until a byte lands on it, since routes only get longer.
+	for time := range steps {
+		if time > 0 && len(path) != 0 && onPath.contains(ms.bytes[time-1]) {
+			path = ms.shortestPath(time)
+			onPath = set[coord]{}
+			for _, pos := range path {
+				onPath.insert(pos)
+			}
 		}
-		visited.insert(curr.pos)
 
-		// -- Skip blocked positions.
-		if curr.isBlocked(ms.bytes) {
-			continue
-		}
+		steps[time] = len(path) - 1
+	}
+
+	return steps
+}
 
-		// -- Found exit.
-		if curr.pos.row == ms.size && curr.pos.col == ms.size {
-			return len(curr.visited)
+func (ms memorySpace) render(time int, path []coord) string {
+	var sb strings.Builder
+
+	onPath := set[coord]{}
+	for _, pos := range path {
+		onPath.insert(pos)
+	}
+
+	for row := 0; row <= ms.size; row += 1 {
+		for col := 0; col <= ms.size; col += 1 {
+			pos := coord{row, col}
+			switch {
+			case ms.isBlocked(pos, time):
+				sb.WriteByte('#')
+			case onPath.contains(pos):
+				sb.WriteByte('O')
+			default:
+				sb.WriteByte('.')
+			}
 		}
+		sb.WriteByte('\n')
+	}
 
-		// -- Try next positions.
-		open = append(open, curr.nextElves(ms)...)
+	return sb.String()
+}
+
+type unionFind struct {
+	parent []int
+	size   []int
+}
+
+func newUnionFind(length int) unionFind {
+	parent := make([]int, length)
+	size := make([]int, length)
+	for index := range parent {
+		parent[index] = index
+		size[index] = 1
 	}
+	return unionFind{parent, size}
+}
 
-	return -1
+func (uf unionFind) find(index int) int {
+	for uf.parent[index] != index {
+		uf.parent[index] = uf.parent[uf.parent[index]]
+		index = uf.parent[index]
+	}
+	return index
 }
 
-func (ms memorySpace) findExitBlockingByte() coord {
-	lTime := 0
-	rTime := len(ms.bytes)
+func (uf unionFind) union(a int, b int) {
+	a = uf.find(a)
+	b = uf.find(b)
+	if a == b {
+		return
+	}
+
+	if uf.size[a] < uf.size[b] {
+		a, b = b, a
+	}
+	uf.parent[b] = a
+	uf.size[a] += uf.size[b]
+}
+
+func (ms memorySpace) index(pos coord) int {
+	return pos.row*(ms.size+1) + pos.col
+}
+
+func (ms memorySpace) findExitBlockingByte() (coord, bool) {
+	uf := newUnionFind((ms.size + 1) * (ms.size + 1))
+	open := make([]bool, len(uf.parent))
+
+	openCell := func(pos coord) {
+		open[ms.index(pos)] = true
+		for _, delta := range directions {
+			next := coord{pos.row + delta.row, pos.col + delta.col}
+			if ms.inBounds(next) && open[ms.index(next)] {
+				uf.union(ms.index(pos), ms.index(next))
+			}
+		}
+	}
+
+	start := coord{0, 0}
+	exit := coord{ms.size, ms.size}
+	connected := func() bool {
+		return open[ms.index(start)] && open[ms.index(exit)] &&
+			uf.find(ms.index(start)) == uf.find(ms.index(exit))
+	}
 
-	for rTime-lTime > 1 {
-		mTime := (lTime + rTime) / 2
-		mSol := ms.minStepsToExit(mTime)
+	// -- Start with every byte fallen.
+	for row := 0; row <= ms.size; row += 1 {
+		for col := 0; col <= ms.size; col += 1 {
+			if _, ok := ms.fallen[coord{row, col}]; !ok {
+				openCell(coord{row, col})
+			}
+		}
+	}
+
+	if connected() {
+		return coord{}, false
+	}
 
-		if mSol != -1 {
-			lTime = mTime
-		} else {
-			rTime = mTime
+	// -- Lift bytes newest first; the one that reconnects the exit blocked it.
+	for fallTime := len(ms.bytes) - 1; fallTime >= 0; fallTime -= 1 {
+		pos := ms.bytes[fallTime]
+		if ms.fallen[pos] != fallTime || !ms.inBounds(pos) {
+			continue
+		}
+
+		openCell(pos)
+		if connected() {
+			return pos, true
 		}
 	}
 
-	return ms.bytes[lTime]
+	return coord{}, false
 }
 
 func main() {
-	ms := newMemorySpace(70, os.Stdin)
-	pos := ms.findExitBlockingByte()
+	size := flag.Int("size", 70, "largest coordinate in the memory space")
+	showPath := flag.Bool("path", false, "draw the last open route and the byte that cuts it")
+	flag.Parse()
+
+	ms := newMemorySpace(*size, os.Stdin)
+	pos, ok := ms.findExitBlockingByte()
+	if !ok {
+		fmt.Println("none")
+		return
+	}
+
+	if *showPath {
+		time := ms.fallen[pos]
+		grid := []byte(ms.render(time, ms.shortestPath(time)))
+		grid[pos.row*(ms.size+2)+pos.col] = 'X'
+		fmt.Print(string(grid))
+	}
 	fmt.Printf("%d,%d\n", pos.col, pos.row)
 }
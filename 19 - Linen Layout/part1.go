@@ -4,30 +4,58 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"iter"
 	"os"
 	"strings"
 )
 
 type color byte
 
-type request struct {
-	towels  [][]color
-	designs [][]color
-	memory  map[string]bool
+type trie struct {
+	children map[color]*trie
+	isTowel  bool
 }
 
-func colorSliceString(colors []color) string {
-	var sb strings.Builder
+func newTrie() *trie {
+	return &trie{children: make(map[color]*trie)}
+}
 
-	for _, c := range colors {
-		sb.WriteByte(byte(c))
+func (t *trie) insert(towel []color) {
+	node := t
+	for _, c := range towel {
+		child, ok := node.children[c]
+		if !ok {
+			child = newTrie()
+			node.children[c] = child
+		}
+		node = child
 	}
+	node.isTowel = true
+}
 
-	return sb.String()
+func (t *trie) prefixLengths(design []color) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		node := t
+		for index, c := range design {
+			node = node.children[c]
+			if node == nil {
+				return
+			}
+			if node.isTowel && !yield(index+1) {
+				return
+			}
+		}
+	}
+}
+
+type request struct {
+	towels  *trie
+	designs [][]color
 }
 
 func newRequest(r io.Reader) (req request) {
 	foundBlank := false
+	req.towels = newTrie()
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -51,7 +79,7 @@ func newRequest(r io.Reader) (req request) {
 					towel = append(towel, c)
 				}
 
-				req.towels = append(req.towels, towel)
+				req.towels.insert(towel)
 			}
 
 			continue
@@ -68,48 +96,24 @@ func newRequest(r io.Reader) (req request) {
 		req.designs = append(req.designs, design)
 	}
 
-	req.memory = make(map[string]bool)
 	return req
 }
 
 func (req request) isPossible(design []color) bool {
-	if len(design) == 0 {
-		return true
-	}
-
-	result, ok := req.memory[colorSliceString(design)]
-	if ok {
-		return result
-	}
-
-	for _, towel := range req.towels {
-		if len(towel) > len(design) {
-			continue
-		}
-
-		// -- See if towel fits design.
-		numMatch := 0
-		for i, c := range towel {
-			if c == design[i] {
-				numMatch += 1
-			} else {
+	// -- possible[i] means design[i:] can be made; fill from the end.
+	possible := make([]bool, len(design)+1)
+	possible[len(design)] = true
+
+	for offset := len(design) - 1; offset >= 0; offset -= 1 {
+		for length := range req.towels.prefixLengths(design[offset:]) {
+			if possible[offset+length] {
+				possible[offset] = true
 				break
 			}
 		}
-
-		if numMatch != len(towel) {
-			continue
-		}
-
-		// -- Check remaining design.
-		if req.isPossible(design[len(towel):]) {
-			req.memory[colorSliceString(design)] = true
-			return true
-		}
 	}
 
-	req.memory[colorSliceString(design)] = false
-	return false
+	return possible[0]
 }
 
 func (req request) score() int {
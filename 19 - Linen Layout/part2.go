@@ -2,18 +2,107 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
+	"iter"
+	"math/big"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 type color byte
 
+type trie struct {
+	children map[color]*trie
+	towel    int
+}
+
+func newTrie() *trie {
+	return &trie{children: make(map[color]*trie), towel: -1}
+}
+
+func (t *trie) insert(towel []color, index int) (int, bool) {
+	node := t
+	for _, c := range towel {
+		child, ok := node.children[c]
+		if !ok {
+			child = newTrie()
+			node.children[c] = child
+		}
+		node = child
+	}
+	if node.towel != -1 {
+		return node.towel, false
+	}
+	node.towel = index
+	return index, true
+}
+
+func (t *trie) prefixes(design []color) iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		node := t
+		for index, c := range design {
+			node = node.children[c]
+			if node == nil {
+				return
+			}
+			if node.towel != -1 && !yield(index+1, node.towel) {
+				return
+			}
+		}
+	}
+}
+
+type towelSpec struct {
+	pattern []color
+	stock   int
+	cost    int
+}
+
+var towelRegex = regexp.MustCompile(`^(\w+)(?:=(\d+))?(?:@(\d+))?$`)
+
+func newTowelSpec(str string) (spec towelSpec) {
+	// -- "pattern", optionally followed by "=stock" and "@cost".
+	matches := towelRegex.FindStringSubmatch(str)
+	if matches == nil {
+		panic(fmt.Sprintf("invalid towel %q", str))
+	}
+
+	for _, ch := range matches[1] {
+		spec.pattern = append(spec.pattern, color(ch))
+	}
+
+	spec.stock = -1
+	if len(matches[2]) != 0 {
+		spec.stock, _ = strconv.Atoi(matches[2])
+	}
+
+	spec.cost = 1
+	if len(matches[3]) != 0 {
+		spec.cost, _ = strconv.Atoi(matches[3])
+	}
+
+	return spec
+}
+
+// -- A towel listed twice pools its stock and keeps the cheaper cost.
+func (spec *towelSpec) merge(other towelSpec) {
+	if spec.stock < 0 || other.stock < 0 {
+		spec.stock = -1
+	} else {
+		spec.stock += other.stock
+	}
+	spec.cost = min(spec.cost, other.cost)
+}
+
 type request struct {
-	towels  [][]color
-	designs [][]color
-	memory  map[string]int
+	towels    *trie
+	inventory []towelSpec
+	designs   [][]color
 }
 
 func colorSliceString(colors []color) string {
@@ -28,6 +117,7 @@ func colorSliceString(colors []color) string {
 
 func newRequest(r io.Reader) (req request) {
 	foundBlank := false
+	req.towels = newTrie()
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -44,14 +134,12 @@ func newRequest(r io.Reader) (req request) {
 		// -- Parse available towels.
 		if !foundBlank {
 			for _, str := range strings.Split(line, ", ") {
-				var towel []color
-
-				for _, ch := range str {
-					c := color(ch)
-					towel = append(towel, c)
+				spec := newTowelSpec(str)
+				if towel, ok := req.towels.insert(spec.pattern, len(req.inventory)); !ok {
+					req.inventory[towel].merge(spec)
+					continue
 				}
-
-				req.towels = append(req.towels, towel)
+				req.inventory = append(req.inventory, spec)
 			}
 
 			continue
@@ -68,66 +156,211 @@ func newRequest(r io.Reader) (req request) {
 		req.designs = append(req.designs, design)
 	}
 
-	req.memory = make(map[string]int)
 	return req
 }
 
-func (req request) countPossible(design []color) (done bool, total int) {
-	if len(design) == 0 {
-		return true, 1
-	}
+func (req request) arrangementCounts(design []color) []*big.Int {
+	// -- counts[i] is the number of ways to make design[i:]; fill from the end.
+	counts := make([]*big.Int, len(design)+1)
+	counts[len(design)] = big.NewInt(1)
 
-	result, ok := req.memory[colorSliceString(design)]
-	if ok {
-		return true, result
+	for offset := len(design) - 1; offset >= 0; offset -= 1 {
+		counts[offset] = new(big.Int)
+		for length := range req.towels.prefixes(design[offset:]) {
+			counts[offset].Add(counts[offset], counts[offset+length])
+		}
 	}
 
-	for _, towel := range req.towels {
-		if len(towel) > len(design) {
-			continue
+	return counts
+}
+
+func (req request) countPossible(design []color) *big.Int {
+	return req.arrangementCounts(design)[0]
+}
+
+func (req request) listArrangements(design []color, limit int) [][]string {
+	counts := req.arrangementCounts(design)
+
+	var arrangements [][]string
+	var parts []string
+
+	// -- Only follow towels whose remainder can still be made.
+	var walk func(offset int) bool
+	walk = func(offset int) bool {
+		if offset == len(design) {
+			arrangements = append(arrangements, slices.Clone(parts))
+			return len(arrangements) < limit
 		}
 
-		// -- See if towel fits design.
-		numMatch := 0
-		for i, c := range towel {
-			if c == design[i] {
-				numMatch += 1
-			} else {
-				break
+		for length := range req.towels.prefixes(design[offset:]) {
+			if counts[offset+length].Sign() == 0 {
+				continue
+			}
+
+			parts = append(parts, colorSliceString(design[offset:offset+length]))
+			more := walk(offset + length)
+			parts = parts[:len(parts)-1]
+			if !more {
+				return false
 			}
 		}
 
-		if numMatch != len(towel) {
-			continue
+		return true
+	}
+
+	if limit > 0 {
+		walk(0)
+	}
+	return arrangements
+}
+
+func (req request) score() *big.Int {
+	score := new(big.Int)
+
+	for _, d := range req.designs {
+		score.Add(score, req.countPossible(d))
+	}
+
+	return score
+}
+
+type arrangement struct {
+	towels []int
+	cost   int
+}
+
+func (req request) cheapest(design []color, stock []int, weight func(towel int) int) (arrangement, bool) {
+	type choice struct {
+		cost  int
+		towel int
+		ok    bool
+	}
+
+	// -- Stock of limited towels is part of the state; unlimited ones never run out.
+	var limited []int
+	for towel, spec := range req.inventory {
+		if spec.stock >= 0 {
+			limited = append(limited, towel)
 		}
+	}
 
-		// -- Check remaining design.
-		subDone, subTotal := req.countPossible(design[len(towel):])
-		if subDone {
-			total += subTotal
+	stateKey := func(offset int) string {
+		key := []int{offset}
+		for _, towel := range limited {
+			key = append(key, stock[towel])
 		}
+		return fmt.Sprint(key)
 	}
 
-	req.memory[colorSliceString(design)] = total
-	done = total != 0
-	return done, total
+	memo := make(map[string]choice)
+
+	var solve func(offset int) choice
+	solve = func(offset int) choice {
+		if offset == len(design) {
+			return choice{ok: true}
+		}
+
+		key := stateKey(offset)
+		if best, ok := memo[key]; ok {
+			return best
+		}
+
+		best := choice{}
+		for length, towel := range req.towels.prefixes(design[offset:]) {
+			if stock[towel] == 0 {
+				continue
+			}
+
+			stock[towel] -= 1
+			rest := solve(offset + length)
+			stock[towel] += 1
+
+			if rest.ok && (!best.ok || weight(towel)+rest.cost < best.cost) {
+				best = choice{weight(towel) + rest.cost, towel, true}
+			}
+		}
+
+		memo[key] = best
+		return best
+	}
+
+	best := solve(0)
+	if !best.ok {
+		return arrangement{}, false
+	}
+
+	// -- Replay the memoised choices, taking towels from stock as we go.
+	result := arrangement{cost: best.cost}
+	for offset := 0; offset != len(design); {
+		towel := solve(offset).towel
+		result.towels = append(result.towels, towel)
+		stock[towel] -= 1
+		offset += len(req.inventory[towel].pattern)
+	}
+
+	return result, true
 }
 
-func (req request) score() int {
-	score := 0
+func (req request) fulfil(weight func(towel int) int) {
+	stock := make([]int, len(req.inventory))
+	for towel, spec := range req.inventory {
+		stock[towel] = spec.stock
+	}
+
+	made := 0
+	total := 0
 
+	// -- Designs are made in order, so earlier ones get first pick of the stock.
 	for _, d := range req.designs {
-		done, total := req.countPossible(d)
-		if done {
-			score += total
+		name := colorSliceString(d)
+
+		best, ok := req.cheapest(d, stock, weight)
+		if !ok {
+			if req.countPossible(d).Sign() != 0 {
+				fmt.Printf("%s: impossible, out of stock\n", name)
+			} else {
+				fmt.Printf("%s: impossible\n", name)
+			}
+			continue
 		}
+
+		var parts []string
+		for _, towel := range best.towels {
+			parts = append(parts, colorSliceString(req.inventory[towel].pattern))
+		}
+		fmt.Printf("%s: %d: %s\n", name, best.cost, strings.Join(parts, ", "))
+
+		made += 1
+		total += best.cost
 	}
 
-	return score
+	fmt.Println(made, total)
 }
 
 func main() {
+	list := flag.Int("list", 0, "print the first K arrangements of each design")
+	minimize := flag.String("minimize", "", "make each design in turn with the fewest towels or lowest cost: towels or cost")
+	flag.Parse()
+
 	req := newRequest(os.Stdin)
-	score := req.score()
-	fmt.Println(score)
+
+	switch *minimize {
+	case "":
+	case "towels":
+		req.fulfil(func(int) int { return 1 })
+		return
+	case "cost":
+		req.fulfil(func(towel int) int { return req.inventory[towel].cost })
+		return
+	default:
+		panic(fmt.Sprintf("unknown objective %q", *minimize))
+	}
+
+	for _, d := range req.designs {
+		for _, parts := range req.listArrangements(d, *list) {
+			fmt.Printf("%s: %s\n", colorSliceString(d), strings.Join(parts, ", "))
+		}
+	}
+
+	fmt.Println(req.score())
 }
@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"slices"
 )
@@ -20,10 +22,6 @@ type coord struct {
 	col int
 }
 
-func manhattanDistance(a coord, b coord) int {
-	return absInt(b.col-a.col) + absInt(b.row-a.row)
-}
-
 var directions = map[byte]coord{
 	'N': {-1, 0},
 	'S': {+1, 0},
@@ -35,7 +33,7 @@ type racetrack struct {
 	start coord
 	end   coord
 	limit coord
-	walls []coord
+	walls [][]bool
 }
 
 func newRacetrack(rdr io.Reader) (rt racetrack) {
@@ -44,12 +42,13 @@ func newRacetrack(rdr io.Reader) (rt racetrack) {
 	scanner := bufio.NewScanner(rdr)
 	for scanner.Scan() {
 		line := scanner.Text()
+		walls := make([]bool, len(line))
 		for col, ch := range line {
 			switch ch {
 			case '.':
 				continue
 			case '#':
-				rt.walls = append(rt.walls, coord{row, col})
+				walls[col] = true
 			case 'S':
 				rt.start.row = row
 				rt.start.col = col
@@ -60,6 +59,7 @@ func newRacetrack(rdr io.Reader) (rt racetrack) {
 				panic("invalid race character")
 			}
 		}
+		rt.walls = append(rt.walls, walls)
 		rt.limit.col = len(line)
 		row += 1
 	}
@@ -76,58 +76,188 @@ func (rt racetrack) inBounds(pos coord) bool {
 }
 
 func (rt racetrack) isWalled(pos coord) bool {
-	return slices.Contains(rt.walls, pos)
+	return rt.walls[pos.row][pos.col]
+}
+
+func (rt racetrack) isTrack(pos coord) bool {
+	return rt.inBounds(pos) && !rt.isWalled(pos)
+}
+
+type distanceGrid [][]int
+
+func (rt racetrack) newDistanceGrid() distanceGrid {
+	grid := make(distanceGrid, rt.limit.row)
+	for row := range grid {
+		grid[row] = make([]int, rt.limit.col)
+		for col := range grid[row] {
+			grid[row][col] = -1
+		}
+	}
+	return grid
+}
+
+func (g distanceGrid) at(pos coord) int {
+	return g[pos.row][pos.col]
 }
 
-func (rt racetrack) getPath() []coord {
-	pos := rt.start
-	path := []coord{pos}
+func (rt racetrack) bfs(from coord) distanceGrid {
+	grid := rt.newDistanceGrid()
+	grid[from.row][from.col] = 0
+	open := []coord{from}
 
-	for pos != rt.end {
-		var next coord
+	for len(open) != 0 {
+		curr := open[0]
+		open = open[1:]
 
 		for _, delta := range directions {
-			next = pos
-			next.row += delta.row
-			next.col += delta.col
-
-			if rt.inBounds(next) &&
-				!rt.isWalled(next) &&
-				!slices.Contains(path, next) {
-				break
+			next := coord{curr.row + delta.row, curr.col + delta.col}
+			if !rt.isTrack(next) || grid.at(next) >= 0 {
+				continue
 			}
+
+			grid[next.row][next.col] = grid.at(curr) + 1
+			open = append(open, next)
 		}
+	}
 
-		pos = next
-		path = append(path, pos)
+	return grid
+}
+
+func (rt racetrack) distances() (fromStart distanceGrid, fromEnd distanceGrid) {
+	fromStart = rt.bfs(rt.start)
+	fromEnd = rt.bfs(rt.end)
+
+	if fromStart.at(rt.end) < 0 {
+		panic("racetrack has no route to the end")
 	}
+	return fromStart, fromEnd
+}
 
-	return path
+type cheat struct {
+	start coord
+	end   coord
+	saved int
 }
 
-func (rt racetrack) findCheats(numCheats int, numSaved int) (count int) {
-	path := rt.getPath()
+type cheatRule struct {
+	duration  int
+	wallsOnly bool
+}
+
+func (rt racetrack) landings(src coord, rule cheatRule, yield func(dst coord, steps int)) {
+	// -- Collision is off, so any track cell within the duration is reachable.
+	if !rule.wallsOnly {
+		for dRow := -rule.duration; dRow <= rule.duration; dRow += 1 {
+			span := rule.duration - absInt(dRow)
+
+			for dCol := -span; dCol <= span; dCol += 1 {
+				dst := coord{src.row + dRow, src.col + dCol}
+				if rt.isTrack(dst) {
+					yield(dst, absInt(dRow)+absInt(dCol))
+				}
+			}
+		}
+		return
+	}
+
+	// -- Otherwise the cheat tunnels through walls only, ending on the first track cell.
+	steps := rt.newDistanceGrid()
+	steps[src.row][src.col] = 0
+	open := []coord{src}
 
-	for i, src := range path {
-		for j, dst := range path {
-			if j <= i {
+	for len(open) != 0 {
+		curr := open[0]
+		open = open[1:]
+
+		if steps.at(curr) == rule.duration {
+			continue
+		}
+
+		for _, delta := range directions {
+			next := coord{curr.row + delta.row, curr.col + delta.col}
+			if !rt.inBounds(next) || steps.at(next) >= 0 {
 				continue
 			}
+			if !rt.isWalled(next) && curr == src {
+				continue
+			}
+
+			steps[next.row][next.col] = steps.at(curr) + 1
+			if rt.isWalled(next) {
+				open = append(open, next)
+			} else {
+				yield(next, steps.at(next))
+			}
+		}
+	}
+}
 
-			distance := manhattanDistance(src, dst)
-			saved := j - i - 2
+func (rt racetrack) findCheats(rule cheatRule, numSaved int) (cheats []cheat) {
+	fromStart, fromEnd := rt.distances()
+	best := fromStart.at(rt.end)
 
-			if distance <= numCheats && saved >= numSaved {
-				count += 1
+	// -- A cheat is worth its saving on the true shortest route, whatever branch it joins.
+	for row := range rt.limit.row {
+		for col := range rt.limit.col {
+			src := coord{row, col}
+			if fromStart.at(src) < 0 {
+				continue
 			}
+
+			rt.landings(src, rule, func(dst coord, steps int) {
+				if fromEnd.at(dst) < 0 {
+					return
+				}
+
+				length := fromStart.at(src) + steps + fromEnd.at(dst)
+				if saved := best - length; saved >= numSaved && saved > 0 {
+					cheats = append(cheats, cheat{src, dst, saved})
+				}
+			})
 		}
 	}
 
-	return count
+	return cheats
 }
 
+func printHistogram(cheats []cheat) {
+	counts := make(map[int]int)
+	for _, c := range cheats {
+		counts[c.saved] += 1
+	}
+
+	savings := slices.Sorted(maps.Keys(counts))
+	for _, saved := range savings {
+		if counts[saved] == 1 {
+			fmt.Printf("There is one cheat that saves %d picoseconds.\n", saved)
+		} else {
+			fmt.Printf("There are %d cheats that save %d picoseconds.\n", counts[saved], saved)
+		}
+	}
+}
+
+const NUM_CHEATS = 2
+
 func main() {
+	duration := flag.Int("duration", NUM_CHEATS, "picoseconds a cheat may last")
+	wallsOnly := flag.Bool("walls-only", false, "cheats may only pass through walls and end on the first track cell")
+	numSaved := flag.Int("save", 100, "only count cheats saving at least this many picoseconds")
+	histogram := flag.Bool("histogram", false, "print how many cheats save each amount of time")
+	list := flag.Bool("list", false, "print each cheat as start, end and time saved")
+	flag.Parse()
+
 	rt := newRacetrack(os.Stdin)
-	cheats := rt.findCheats(2, 100)
-	fmt.Println(cheats)
+	cheats := rt.findCheats(cheatRule{*duration, *wallsOnly}, *numSaved)
+
+	if *list {
+		for _, c := range cheats {
+			fmt.Printf("%d,%d -> %d,%d saves %d\n", c.start.col, c.start.row, c.end.col, c.end.row, c.saved)
+		}
+	}
+
+	if *histogram {
+		printHistogram(cheats)
+		return
+	}
+	fmt.Println(len(cheats))
 }
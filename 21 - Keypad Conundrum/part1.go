@@ -2,7 +2,10 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
+	"iter"
 	"os"
 	"slices"
 	"strconv"
@@ -19,43 +22,6 @@ func (s set[T]) insert(value T) {
 	s[value] = struct{}{}
 }
 
-func permutations[T any](arr []T) (res [][]T) {
-	var perm func([]T, int)
-	perm = func(arr []T, n int) {
-		if n == 1 {
-			tmp := make([]T, len(arr))
-			copy(tmp, arr)
-			res = append(res, tmp)
-		} else {
-			for i := 0; i < n; i += 1 {
-				perm(arr, n-1)
-
-				if n%2 == 1 {
-					tmp := arr[i]
-					arr[i] = arr[n-1]
-					arr[n-1] = tmp
-				} else {
-					tmp := arr[0]
-					arr[0] = arr[n-1]
-					arr[n-1] = tmp
-				}
-			}
-		}
-	}
-	perm(arr, len(arr))
-	return res
-}
-
-func permutationsString(s string) (res []string) {
-	perms := permutations([]byte(s))
-
-	for _, perm := range perms {
-		res = append(res, string(perm))
-	}
-
-	return res
-}
-
 type coord struct {
 	row int
 	col int
@@ -68,133 +34,376 @@ var dirs = map[byte]coord{
 	'<': {0, -1},
 }
 
-var numpad = map[byte]coord{
-	'7': {0, 0}, '8': {0, 1}, '9': {0, 2},
-	'4': {1, 0}, '5': {1, 1}, '6': {1, 2},
-	'1': {2, 0}, '2': {2, 1}, '3': {2, 2},
-	' ': {3, 0}, '0': {3, 1}, 'A': {3, 2},
+type keypad struct {
+	name string
+	keys map[byte]coord
+	gaps set[coord]
+	size coord
 }
 
-func validNumpadPos(pos coord) bool {
-	lim := numpad['A']
-	return pos.row >= 0 &&
-		pos.col >= 0 &&
-		pos.row <= lim.row &&
-		pos.col <= lim.col &&
-		pos != numpad[' ']
-}
+func newKeypad(name string, rows []string) (k keypad) {
+	k.name = name
+	k.keys = make(map[byte]coord)
+	k.gaps = newSet[coord]()
+
+	// -- Blanks are gaps the arm must never pass over.
+	for row, line := range rows {
+		for col := range len(line) {
+			pos := coord{row, col}
+			if line[col] == ' ' {
+				k.gaps.insert(pos)
+				continue
+			}
+			if _, ok := k.keys[line[col]]; ok {
+				panic(fmt.Sprintf("keypad %s has key %q twice", name, line[col]))
+			}
+			k.keys[line[col]] = pos
+		}
+		k.size.row = row + 1
+		k.size.col = max(k.size.col, len(line))
+	}
+
+	// -- Short rows leave gaps at their ends.
+	for row, line := range rows {
+		for col := len(line); col < k.size.col; col += 1 {
+			k.gaps.insert(coord{row, col})
+		}
+	}
 
-var dirpad = map[byte]coord{
-	' ': {0, 0}, '^': {0, 1}, 'A': {0, 2},
-	'<': {1, 0}, 'v': {1, 1}, '>': {1, 2},
+	if _, ok := k.keys['A']; !ok {
+		panic(fmt.Sprintf("keypad %s has no A key to start on", name))
+	}
+	return k
 }
 
-func validDirpadPos(pos coord) bool {
-	lim := dirpad['>']
+func (k keypad) validPos(pos coord) bool {
+	_, isGap := k.gaps[pos]
 	return pos.row >= 0 &&
 		pos.col >= 0 &&
-		pos.row <= lim.row &&
-		pos.col <= lim.col &&
-		pos != dirpad[' ']
+		pos.row < k.size.row &&
+		pos.col < k.size.col &&
+		!isGap
 }
 
-func validKeypadPos(pos coord, isDirpad bool) bool {
-	if isDirpad {
-		return validDirpadPos(pos)
+func (k keypad) canDrive() bool {
+	for _, button := range []byte("^v<>A") {
+		if _, ok := k.keys[button]; !ok {
+			return false
+		}
 	}
-	return validNumpadPos(pos)
+	return true
 }
 
-func getKeyPresses(start coord, end coord) string {
-	var sb strings.Builder
+const defaultLayouts = `numpad
+789
+456
+123
+ 0A
+
+dirpad
+ ^A
+<v>
+`
+
+// -- Longest press sequence built as a string or replayed in full.
+const MAX_SEQUENCE = 1 << 24
+
+func parseLayouts(r io.Reader, keypads map[string]keypad) {
+	// -- A name line, then the grid, with a blank line between keypads.
+	var name string
+	var rows []string
+
+	flush := func() {
+		if len(name) != 0 {
+			keypads[name] = newKeypad(name, rows)
+		}
+		name, rows = "", nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case len(strings.TrimSpace(line)) == 0:
+			flush()
+		case len(name) == 0:
+			name = strings.TrimSpace(line)
+		default:
+			rows = append(rows, line)
+		}
+	}
+	flush()
+}
+
+func parseChain(spec string, keypads map[string]keypad) (chain []keypad) {
+	// -- Door keypad first, e.g. "numpad,dirpad*25".
+	for _, part := range strings.Split(spec, ",") {
+		name, countStr, hasCount := strings.Cut(strings.TrimSpace(part), "*")
+
+		count := 1
+		if hasCount {
+			var err error
+			count, err = strconv.Atoi(countStr)
+			if err != nil {
+				panic(err)
+			}
+		}
 
-	diffRow := end.row - start.row
-	diffCol := end.col - start.col
+		k, ok := keypads[name]
+		if !ok {
+			panic(fmt.Sprintf("unknown keypad %q", name))
+		}
 
-	for range diffRow {
-		sb.WriteByte('v')
+		for range count {
+			chain = append(chain, k)
+		}
 	}
 
-	for range -diffRow {
-		sb.WriteByte('^')
+	for _, k := range chain[1:] {
+		if !k.canDrive() {
+			panic(fmt.Sprintf("keypad %s cannot drive a robot arm", k.name))
+		}
 	}
+	return chain
+}
+
+func validMoves(pad keypad, pos coord, press coord) (moves []string) {
+	// -- Shortest distances over the keys, so gaps that force a detour still route.
+	dist := map[coord]int{pos: 0}
+	open := []coord{pos}
+
+	for len(open) != 0 {
+		curr := open[0]
+		open = open[1:]
+
+		for _, button := range []byte("^v<>") {
+			next := coord{curr.row + dirs[button].row, curr.col + dirs[button].col}
+			if _, seen := dist[next]; seen || !pad.validPos(next) {
+				continue
+			}
 
-	for range diffCol {
-		sb.WriteByte('>')
+			dist[next] = dist[curr] + 1
+			open = append(open, next)
+		}
 	}
 
-	for range -diffCol {
-		sb.WriteByte('<')
+	if _, ok := dist[press]; !ok {
+		panic(fmt.Sprintf("keypad %s has no way from %v to %v", pad.name, pos, press))
 	}
 
-	return sb.String()
+	// -- Walk back from the press along every step that stays on a shortest route.
+	var walk func(curr coord, route []byte)
+	walk = func(curr coord, route []byte) {
+		if curr == pos {
+			moves = append(moves, string(route))
+			return
+		}
+
+		for _, button := range []byte("^v<>") {
+			prev := coord{curr.row - dirs[button].row, curr.col - dirs[button].col}
+			if d, ok := dist[prev]; ok && d == dist[curr]-1 {
+				walk(prev, append([]byte{button}, route...))
+			}
+		}
+	}
+	walk(press, nil)
+
+	slices.Sort(moves)
+	return moves
 }
 
-func countPresses(sequence string, depth int, isDirpad bool, pos coord) int {
+type node struct {
+	sequence string
+	layer    int
+	pos      coord
+}
+
+type solver struct {
+	chain   []keypad
+	lengths map[node]int
+}
+
+func newSolver(chain []keypad) *solver {
+	return &solver{chain, make(map[node]int)}
+}
+
+func (s *solver) keyAt(layer int, key byte) coord {
+	pos, ok := s.chain[layer].keys[key]
+	if !ok {
+		panic(fmt.Sprintf("keypad %s has no key %q", s.chain[layer].name, key))
+	}
+	return pos
+}
+
+func (s *solver) countPresses(sequence string, layer int, pos coord) int {
 	// -- Return empty if done.
 	if len(sequence) == 0 {
 		return 0
 	}
 
-	// -- Get current keypad.
-	keypad := numpad
-	if isDirpad {
-		keypad = dirpad
+	// -- Check if cached result exists.
+	node := node{sequence, layer, pos}
+	cached, ok := s.lengths[node]
+	if ok {
+		return cached
 	}
 
-	press := keypad[sequence[0]]
-	buttons := getKeyPresses(pos, press)
+	press := s.keyAt(layer, sequence[0])
+	moves := validMoves(s.chain[layer], pos, press)
 
 	// -- Get current minimum length.
 	var min_len int
 
-	if depth != 0 {
-		var perm_lengths []int
+	if layer != len(s.chain)-1 {
+		_, min_len = s.bestMove(moves, layer)
+	} else {
+		min_len = len(moves[0]) + 1
+	}
+
+	result := min_len + s.countPresses(sequence[1:], layer, press)
+	s.lengths[node] = result
+	return result
+}
+
+func (s *solver) bestMove(moves []string, layer int) (string, int) {
+	best := ""
+	bestLen := -1
 
-		perms := permutationsString(buttons)
-		perm_set := newSet[string]()
-		for _, perm := range perms {
-			perm_set.insert(perm)
+	for _, move := range moves {
+		length := s.countPresses(move+"A", layer+1, s.keyAt(layer+1, 'A'))
+		if bestLen < 0 || length < bestLen {
+			best, bestLen = move, length
 		}
+	}
 
-		for perm := range perm_set {
-			curr := pos
-			invalid := false
+	return best + "A", bestLen
+}
 
-			for _, button := range perm {
-				delta := dirs[byte(button)]
-				curr.row += delta.row
-				curr.col += delta.col
+func (s *solver) count(code string) int {
+	return s.countPresses(code, 0, s.keyAt(0, 'A'))
+}
 
-				if !validKeypadPos(curr, isDirpad) {
-					invalid = true
-					break
-				}
-			}
+func (s *solver) emit(sequence string, layer int, yield func(byte) bool) bool {
+	pos := s.keyAt(layer, 'A')
 
-			if !invalid {
-				perm += "A"
-				perm_lengths = append(perm_lengths,
-					countPresses(perm, depth-1, true, dirpad['A']))
+	// -- Expand one key at a time, so only the current branch is ever held.
+	for index := range len(sequence) {
+		press := s.keyAt(layer, sequence[index])
+		moves := validMoves(s.chain[layer], pos, press)
+		pos = press
 
+		if layer == len(s.chain)-1 {
+			for _, button := range []byte(moves[0] + "A") {
+				if !yield(button) {
+					return false
+				}
 			}
+			continue
 		}
 
-		if len(perm_lengths) == 0 {
-			perm_lengths = append(perm_lengths, countPresses("A", depth-1, true, dirpad['A']))
+		move, _ := s.bestMove(moves, layer)
+		if !s.emit(move, layer+1, yield) {
+			return false
 		}
+	}
 
-		min_len = slices.Min(perm_lengths)
-	} else {
-		min_len = len(buttons) + 1
+	return true
+}
+
+func (s *solver) presses(code string) iter.Seq[byte] {
+	return func(yield func(byte) bool) {
+		s.emit(code, 0, yield)
+	}
+}
+
+func (s *solver) sequence(code string) string {
+	if length := s.count(code); length > MAX_SEQUENCE {
+		panic(fmt.Sprintf("%s needs %d presses, too many to show; pass -head N to see the start", code, length))
 	}
 
-	result := min_len + countPresses(sequence[1:], depth, isDirpad, press)
-	return result
+	var sb strings.Builder
+	for button := range s.presses(code) {
+		sb.WriteByte(button)
+	}
+	return sb.String()
+}
+
+func simulate(chain []keypad, presses iter.Seq[byte]) string {
+	arms := make([]coord, len(chain))
+	for layer, pad := range chain {
+		arms[layer] = pad.keys['A']
+	}
+
+	var typed strings.Builder
+
+	// -- A press either moves the arm one layer down or presses the key under it.
+	for button := range presses {
+		layer := len(chain) - 1
+
+		for {
+			if delta, ok := dirs[button]; ok {
+				arms[layer].row += delta.row
+				arms[layer].col += delta.col
+				if !chain[layer].validPos(arms[layer]) {
+					panic(fmt.Sprintf("arm over a gap on keypad %s", chain[layer].name))
+				}
+				break
+			}
+			if button != 'A' {
+				panic(fmt.Sprintf("keypad %s cannot be driven by %q", chain[layer].name, button))
+			}
+
+			button = 0
+			for key, pos := range chain[layer].keys {
+				if pos == arms[layer] {
+					button = key
+				}
+			}
+
+			if layer == 0 {
+				typed.WriteByte(button)
+				break
+			}
+			layer -= 1
+		}
+	}
+
+	return typed.String()
+}
+
+func codeNumber(code string) int {
+	// -- Complexity uses the digits in the code, whatever else the keypad has.
+	num := 0
+	for _, ch := range code {
+		if ch >= '0' && ch <= '9' {
+			num = num*10 + int(ch-'0')
+		}
+	}
+	return num
 }
 
 func main() {
-	file, err := os.Open(os.Args[1])
+	layoutsPath := flag.String("layouts", "", "file of extra keypad layouts: a name line, then the grid with blanks as gaps")
+	show := flag.Bool("show", false, "print an optimal press sequence for each code")
+	head := flag.Int("head", 0, "print only the first N presses of each sequence, streaming them")
+	verify := flag.Bool("verify", false, "replay each sequence through the chain and check it types the code")
+	chainSpec := flag.String("chain", "numpad,dirpad*2", "keypads from the door outward, e.g. numpad,dirpad*2")
+	flag.Parse()
+
+	keypads := make(map[string]keypad)
+	parseLayouts(strings.NewReader(defaultLayouts), keypads)
+	if len(*layoutsPath) != 0 {
+		layouts, err := os.Open(*layoutsPath)
+		if err != nil {
+			panic(err)
+		}
+		parseLayouts(layouts, keypads)
+		layouts.Close()
+	}
+	chain := parseChain(*chainSpec, keypads)
+	solver := newSolver(chain)
+
+	file, err := os.Open(flag.Arg(0))
 	if err != nil {
 		panic(err)
 	}
@@ -203,13 +412,33 @@ func main() {
 	total := 0
 	for scanner.Scan() {
 		line := scanner.Text()
-		codeNumStr := strings.TrimSuffix(line, "A")
-		codeNum, err := strconv.Atoi(codeNumStr)
-		if err != nil {
-			panic(err)
+		presses := solver.count(line)
+		total += codeNumber(line) * presses
+
+		switch {
+		case *head > 0:
+			var sb strings.Builder
+			for button := range solver.presses(line) {
+				if sb.Len() == *head {
+					break
+				}
+				sb.WriteByte(button)
+			}
+			fmt.Printf("%s: %s... (%d presses)\n", line, sb.String(), presses)
+		case *show && presses > MAX_SEQUENCE:
+			fmt.Printf("%s: %d presses, too many to show; pass -head N to see the start\n", line, presses)
+		case *show:
+			fmt.Printf("%s: %s\n", line, solver.sequence(line))
+		}
+
+		if *verify {
+			if presses > MAX_SEQUENCE {
+				panic(fmt.Sprintf("%s needs %d presses, too many to verify; pass a shorter -chain", line, presses))
+			}
+			if typed := simulate(chain, solver.presses(line)); typed != line {
+				panic(fmt.Sprintf("sequence for %s types %s", line, typed))
+			}
 		}
-		presses := countPresses(line, 2, false, numpad['A'])
-		total += codeNum * presses
 	}
 
 	fmt.Println(total)
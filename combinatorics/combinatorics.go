@@ -0,0 +1,126 @@
+// Package combinatorics provides allocation-free iterators over
+// combinations, permutations and cartesian products.
+//
+// Every iterator yields the same backing slice on each step, so callers
+// that keep a result past the current iteration must clone it.
+package combinatorics
+
+import (
+	"iter"
+	"slices"
+)
+
+// Combinations yields every size-element combination of arr, in index order.
+func Combinations[T any](arr []T, size int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		length := len(arr)
+		if size < 0 || size > length {
+			return
+		}
+
+		indices := make([]int, size)
+		combo := make([]T, size)
+		for index := range indices {
+			indices[index] = index
+			combo[index] = arr[index]
+		}
+
+		for {
+			if !yield(combo) {
+				return
+			}
+
+			// -- Find the rightmost index that can still advance.
+			pivot := size - 1
+			for pivot >= 0 && indices[pivot] == pivot+length-size {
+				pivot -= 1
+			}
+			if pivot < 0 {
+				return
+			}
+
+			// -- Advance it and reset everything to its right.
+			indices[pivot] += 1
+			combo[pivot] = arr[indices[pivot]]
+			for index := pivot + 1; index < size; index += 1 {
+				indices[index] = indices[index-1] + 1
+				combo[index] = arr[indices[index]]
+			}
+		}
+	}
+}
+
+// Permutations yields every ordering of arr using Heap's algorithm.
+// arr itself is left untouched.
+func Permutations[T any](arr []T) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		perm := slices.Clone(arr)
+		counters := make([]int, len(perm))
+
+		if !yield(perm) {
+			return
+		}
+
+		for index := 1; index < len(perm); {
+			if counters[index] >= index {
+				counters[index] = 0
+				index += 1
+				continue
+			}
+
+			if index%2 == 0 {
+				perm[0], perm[index] = perm[index], perm[0]
+			} else {
+				perm[counters[index]], perm[index] = perm[index], perm[counters[index]]
+			}
+
+			if !yield(perm) {
+				return
+			}
+
+			counters[index] += 1
+			index = 1
+		}
+	}
+}
+
+// Product yields the cartesian product of sets, with the last set varying
+// fastest.
+func Product[T any](sets ...[]T) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		for _, set := range sets {
+			if len(set) == 0 {
+				return
+			}
+		}
+
+		indices := make([]int, len(sets))
+		tuple := make([]T, len(sets))
+		for index, set := range sets {
+			tuple[index] = set[0]
+		}
+
+		for {
+			if !yield(tuple) {
+				return
+			}
+
+			// -- Tick the odometer from the right.
+			pos := len(sets) - 1
+			for ; pos >= 0; pos -= 1 {
+				indices[pos] += 1
+				if indices[pos] < len(sets[pos]) {
+					tuple[pos] = sets[pos][indices[pos]]
+					break
+				}
+
+				indices[pos] = 0
+				tuple[pos] = sets[pos][0]
+			}
+
+			if pos < 0 {
+				return
+			}
+		}
+	}
+}
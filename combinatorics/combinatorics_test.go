@@ -0,0 +1,174 @@
+package combinatorics
+
+import (
+	"fmt"
+	"iter"
+	"math/bits"
+	"slices"
+	"testing"
+)
+
+func collect[T any](seq iter.Seq[[]T]) (out [][]T) {
+	for item := range seq {
+		out = append(out, slices.Clone(item))
+	}
+	return out
+}
+
+func TestCombinations(t *testing.T) {
+	got := collect(Combinations([]int{1, 2, 3, 4}, 2))
+	want := [][]int{{1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestCombinationsSizes(t *testing.T) {
+	arr := []int{1, 2, 3}
+
+	tests := []struct {
+		size int
+		want int
+	}{
+		{size: 0, want: 1},
+		{size: 3, want: 1},
+		{size: 4, want: 0},
+		{size: -1, want: 0},
+	}
+
+	for _, test := range tests {
+		got := collect(Combinations(arr, test.size))
+		if len(got) != test.want {
+			t.Errorf("size %d: got %d combinations, want %d", test.size, len(got), test.want)
+		}
+		for _, combo := range got {
+			if len(combo) != test.size {
+				t.Errorf("size %d: got combination %v", test.size, combo)
+			}
+		}
+	}
+}
+
+func TestPermutations(t *testing.T) {
+	arr := []int{1, 2, 3, 4}
+	seen := make(map[string]bool)
+
+	for perm := range Permutations(arr) {
+		key := fmt.Sprint(perm)
+		if seen[key] {
+			t.Fatalf("permutation %v yielded twice", perm)
+		}
+		seen[key] = true
+
+		sorted := slices.Sorted(slices.Values(perm))
+		if !slices.Equal(sorted, arr) {
+			t.Fatalf("permutation %v is not a reordering of %v", perm, arr)
+		}
+	}
+
+	if len(seen) != 24 {
+		t.Fatalf("got %d permutations, want 24", len(seen))
+	}
+	if !slices.Equal(arr, []int{1, 2, 3, 4}) {
+		t.Fatalf("input modified to %v", arr)
+	}
+}
+
+func TestPermutationsEmpty(t *testing.T) {
+	got := collect(Permutations([]int{}))
+	if len(got) != 1 || len(got[0]) != 0 {
+		t.Fatalf("got %v, want one empty permutation", got)
+	}
+}
+
+func TestProduct(t *testing.T) {
+	got := collect(Product([]int{1, 2}, []int{3, 4, 5}))
+	want := [][]int{{1, 3}, {1, 4}, {1, 5}, {2, 3}, {2, 4}, {2, 5}}
+
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestProductEmptySet(t *testing.T) {
+	if got := collect(Product([]int{1, 2}, []int{}, []int{3})); len(got) != 0 {
+		t.Fatalf("got %v, want nothing", got)
+	}
+}
+
+func TestEarlyTermination(t *testing.T) {
+	seqs := map[string]iter.Seq[[]int]{
+		"Combinations": Combinations([]int{1, 2, 3, 4, 5}, 2),
+		"Permutations": Permutations([]int{1, 2, 3, 4, 5}),
+		"Product":      Product([]int{1, 2, 3}, []int{4, 5, 6}),
+	}
+
+	for name, seq := range seqs {
+		count := 0
+		for range seq {
+			count += 1
+			if count == 3 {
+				break
+			}
+		}
+
+		if count != 3 {
+			t.Errorf("%s: stopped after %d items, want 3", name, count)
+		}
+	}
+}
+
+// -- Goroutine and channel generator that Combinations replaced, kept as a baseline.
+func channelCombinations[T any](arr []T, size int) <-chan []T {
+	length := uint(len(arr))
+
+	out := make(chan []T)
+
+	go func() {
+		defer close(out)
+
+		for comboBits := 1; comboBits < (1 << length); comboBits += 1 {
+			if bits.OnesCount(uint(comboBits)) != size {
+				continue
+			}
+
+			var combo []T
+
+			for index := uint(0); index < length; index += 1 {
+				if (comboBits>>index)&1 == 1 {
+					combo = append(combo, arr[index])
+				}
+			}
+
+			out <- combo
+		}
+	}()
+
+	return out
+}
+
+func BenchmarkCombinations(b *testing.B) {
+	arr := make([]int, 16)
+	for index := range arr {
+		arr[index] = index
+	}
+
+	b.Run("channel", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for combo := range channelCombinations(arr, 2) {
+				_ = combo
+			}
+		}
+	})
+
+	b.Run("iterator", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for combo := range Combinations(arr, 2) {
+				_ = combo
+			}
+		}
+	})
+}
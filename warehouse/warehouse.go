@@ -0,0 +1,334 @@
+// Package warehouse simulates the day 15 robot pushing boxes of any width
+// around a walled grid.
+//
+// Walls and boxes live in an occupancy grid, so every collision check is a
+// single lookup, and each push is planned over a frontier of touched boxes
+// before anything moves. Moves are kept in a log that can be undone and redone.
+package warehouse
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Coord is a grid cell, row first.
+type Coord struct {
+	Row int
+	Col int
+}
+
+// Directions maps each move character to its unit step.
+var Directions = map[byte]Coord{
+	'^': {-1, 0},
+	'v': {+1, 0},
+	'>': {0, +1},
+	'<': {0, -1},
+}
+
+var opposite = map[byte]byte{
+	'^': 'v',
+	'v': '^',
+	'>': '<',
+	'<': '>',
+}
+
+func (c Coord) toward(dir byte) Coord {
+	delta := Directions[dir]
+	c.Row += delta.Row
+	c.Col += delta.Col
+	return c
+}
+
+// Move records one robot step: where the robot stood, which boxes it pushed,
+// and whether a wall stopped it.
+type Move struct {
+	Dir     byte
+	Robot   Coord
+	Pushed  []int
+	Blocked bool
+}
+
+// Warehouse is the robot, its boxes and the walls around them.
+type Warehouse struct {
+	Rows     int
+	Cols     int
+	BoxWidth int
+	Robot    Coord
+
+	walls []bool
+	cells []int
+	boxes []Coord
+
+	// -- Frontier bookkeeping, reused across pushes.
+	seen  []int
+	stamp int
+
+	history []Move
+	future  []Move
+}
+
+// Parse reads the map up to the first blank line, stretching every input
+// column to boxWidth cells.
+func Parse(scanner *bufio.Scanner, boxWidth int) *Warehouse {
+	if boxWidth < 1 {
+		panic("box width must be positive")
+	}
+
+	var lines []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	w := &Warehouse{Rows: len(lines), BoxWidth: boxWidth}
+	for _, line := range lines {
+		w.Cols = max(w.Cols, len(line)*boxWidth)
+	}
+
+	w.walls = make([]bool, w.Rows*w.Cols)
+	w.cells = make([]int, w.Rows*w.Cols)
+	for index := range w.cells {
+		w.cells[index] = -1
+	}
+
+	for row, line := range lines {
+		for col, ch := range line {
+			pos := Coord{row, col * boxWidth}
+
+			switch ch {
+			case '#':
+				for offset := range boxWidth {
+					w.walls[w.index(Coord{pos.Row, pos.Col + offset})] = true
+				}
+			case 'O':
+				w.boxes = append(w.boxes, pos)
+				w.place(len(w.boxes)-1, pos)
+			case '@':
+				w.Robot = pos
+			case '.':
+				continue
+			default:
+				panic(fmt.Sprintf("invalid warehouse character %q", ch))
+			}
+		}
+	}
+
+	w.seen = make([]int, len(w.boxes))
+
+	return w
+}
+
+// ParseMoves reads every remaining line as a string of move characters.
+func ParseMoves(scanner *bufio.Scanner) (dirs []byte) {
+	for scanner.Scan() {
+		line := scanner.Text()
+		for i := range line {
+			if _, ok := Directions[line[i]]; !ok {
+				panic(fmt.Sprintf("invalid move character %q", line[i]))
+			}
+			dirs = append(dirs, line[i])
+		}
+	}
+
+	return dirs
+}
+
+func (w *Warehouse) index(pos Coord) int {
+	return pos.Row*w.Cols + pos.Col
+}
+
+func (w *Warehouse) isWall(pos Coord) bool {
+	// -- Anything off the map counts as wall, so open edges still stop pushes.
+	if pos.Row < 0 || pos.Row >= w.Rows || pos.Col < 0 || pos.Col >= w.Cols {
+		return true
+	}
+	return w.walls[w.index(pos)]
+}
+
+func (w *Warehouse) boxAt(pos Coord) int {
+	if w.isWall(pos) {
+		return -1
+	}
+	return w.cells[w.index(pos)]
+}
+
+func (w *Warehouse) place(id int, left Coord) {
+	for offset := range w.BoxWidth {
+		w.cells[w.index(Coord{left.Row, left.Col + offset})] = id
+	}
+}
+
+func (w *Warehouse) lift(id int) {
+	left := w.boxes[id]
+	for offset := range w.BoxWidth {
+		w.cells[w.index(Coord{left.Row, left.Col + offset})] = -1
+	}
+}
+
+func (w *Warehouse) planPush(first int, dir byte) ([]int, bool) {
+	w.stamp += 1
+	w.seen[first] = w.stamp
+	pushed := []int{first}
+
+	// -- Nothing moves until every box on the frontier is known to be free.
+	for i := 0; i < len(pushed); i += 1 {
+		id := pushed[i]
+		left := w.boxes[id]
+
+		for offset := range w.BoxWidth {
+			next := Coord{left.Row, left.Col + offset}.toward(dir)
+
+			if w.isWall(next) {
+				return nil, false
+			}
+
+			other := w.cells[w.index(next)]
+			if other >= 0 && other != id && w.seen[other] != w.stamp {
+				w.seen[other] = w.stamp
+				pushed = append(pushed, other)
+			}
+		}
+	}
+
+	return pushed, true
+}
+
+func (w *Warehouse) shift(pushed []int, dir byte) {
+	// -- Lift every box before placing any, so neighbours never overwrite each other.
+	for _, id := range pushed {
+		w.lift(id)
+	}
+	for _, id := range pushed {
+		w.boxes[id] = w.boxes[id].toward(dir)
+		w.place(id, w.boxes[id])
+	}
+}
+
+func (w *Warehouse) apply(m Move) {
+	if m.Blocked {
+		return
+	}
+
+	w.shift(m.Pushed, m.Dir)
+	w.Robot = m.Robot.toward(m.Dir)
+}
+
+func (w *Warehouse) revert(m Move) {
+	if !m.Blocked {
+		w.shift(m.Pushed, opposite[m.Dir])
+	}
+	w.Robot = m.Robot
+}
+
+// Step moves the robot one cell, pushing whatever boxes are in the way, and
+// logs the move. It clears anything that could have been redone.
+func (w *Warehouse) Step(dir byte) Move {
+	m := Move{Dir: dir, Robot: w.Robot}
+	next := w.Robot.toward(dir)
+
+	if w.isWall(next) {
+		m.Blocked = true
+	} else if id := w.boxAt(next); id >= 0 {
+		pushed, ok := w.planPush(id, dir)
+		m.Pushed, m.Blocked = pushed, !ok
+	}
+
+	w.apply(m)
+	w.history = append(w.history, m)
+	w.future = nil
+
+	return m
+}
+
+// Undo takes back the most recent move, reporting false when there is none.
+func (w *Warehouse) Undo() bool {
+	if len(w.history) == 0 {
+		return false
+	}
+
+	m := w.history[len(w.history)-1]
+	w.history = w.history[:len(w.history)-1]
+	w.revert(m)
+	w.future = append(w.future, m)
+
+	return true
+}
+
+// Redo replays the most recently undone move, reporting false when there is none.
+func (w *Warehouse) Redo() bool {
+	if len(w.future) == 0 {
+		return false
+	}
+
+	m := w.future[len(w.future)-1]
+	w.future = w.future[:len(w.future)-1]
+	w.apply(m)
+	w.history = append(w.history, m)
+
+	return true
+}
+
+// Seek undoes, redoes or steps through dirs until exactly n moves are applied.
+func (w *Warehouse) Seek(dirs []byte, n int) {
+	n = max(0, min(n, len(dirs)))
+
+	for len(w.history) > n {
+		w.Undo()
+	}
+	for len(w.history) < n {
+		if !w.Redo() {
+			w.Step(dirs[len(w.history)])
+		}
+	}
+}
+
+// History returns the applied moves, oldest first.
+func (w *Warehouse) History() []Move {
+	return w.history
+}
+
+// Score sums the GPS coordinate of each box's left edge.
+func (w *Warehouse) Score() (score int) {
+	for _, left := range w.boxes {
+		score += (left.Row * 100) + left.Col
+	}
+	return score
+}
+
+// String draws the map the way the puzzle does, with wide boxes as [==].
+func (w *Warehouse) String() string {
+	var sb strings.Builder
+
+	for row := range w.Rows {
+		for col := range w.Cols {
+			pos := Coord{row, col}
+
+			switch id := w.boxAt(pos); {
+			case pos == w.Robot:
+				sb.WriteByte('@')
+			case w.isWall(pos):
+				sb.WriteByte('#')
+			case id < 0:
+				sb.WriteByte('.')
+			case w.BoxWidth == 1:
+				sb.WriteByte('O')
+			case col == w.boxes[id].Col:
+				sb.WriteByte('[')
+			case col == w.boxes[id].Col+w.BoxWidth-1:
+				sb.WriteByte(']')
+			default:
+				sb.WriteByte('=')
+			}
+		}
+
+		if row != w.Rows-1 {
+			sb.WriteByte('\n')
+		}
+	}
+
+	return sb.String()
+}
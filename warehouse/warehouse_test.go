@@ -0,0 +1,252 @@
+package warehouse
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const smallExample = `########
+#..O.O.#
+##@.O..#
+#...O..#
+#.#.O..#
+#...O..#
+#......#
+########
+
+<^^>>>vv<v>>v<<
+`
+
+const largeExample = `##########
+#..O..O.O#
+#......O.#
+#.OO..O.O#
+#..O@..O.#
+#O#..O...#
+#O..O..O.#
+#.OO.O.OO#
+#....O...#
+##########
+
+<vv>^<v^>v>^vv^v>v<>v^v<v<^vv<<<^><<><>>v<vvv<>^v^>^<<<><<v<<<v^vv^v>^
+vvv<<^>^v^^><<>>><>^<<><^vv^^<>vvv<>><^^v>^>vv<>v<<<<v<^v>^<^^>>>^<v<v
+><>vv>v^v^<>><>>>><^^>vv>v<^^^>>v^v^<^^>v^^>v^<^v>v<>>v^v^<v>v^^<^^vv<
+<<v<^>>^^^^>>>v^<>vvv^><v<<<>^^^vv^<vvv>^>v<^^^^v<>^>vvvv><>>v^<<^^^^^
+^><^><>>><>^^<<^^v>>><^<v>^<vv>>v>>>^v><>^v><<<<v>>v<v<v>vvv>^<><<>^><
+^>><>^v<><^vvv<^^<><v<<<<<><^v<<<><<<^^<v<^^^><^>>^<v^><<<^>>^v<v^v<v^
+>^>>^v>vv>^<<^v<>><<><<v<<v><>v<^vv<<<>^^v^>^^>>><<^v>>v^v><^^>>^<>vv^
+<><^^>^^^<><vvvvv^v<v<<>^v<v>v<<^><<><<><<<^^<<<^<<>><<><^^^>^^<>^>v<>
+^^>vv<^v^v<vv>^<><v<^v>^^^>>>^^vvv^>vvv<>>>^<^>>>>>^<<^v>^vvv<>^<><<v>
+v^^>>><<^^<>>^v^<v^vv<>v^<<>^<^v^v><^<<<><<^<v><v<>vv>>v><v^<vv<>v^<<^
+`
+
+func parse(input string, boxWidth int) (*Warehouse, []byte) {
+	s := bufio.NewScanner(strings.NewReader(input))
+	w := Parse(s, boxWidth)
+	return w, ParseMoves(s)
+}
+
+func run(input string, boxWidth int) int {
+	w, dirs := parse(input, boxWidth)
+	for _, dir := range dirs {
+		w.Step(dir)
+	}
+	return w.Score()
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		boxWidth int
+		want     int
+	}{
+		{"small", smallExample, 1, 2028},
+		{"large", largeExample, 1, 10092},
+		{"large wide", largeExample, 2, 9021},
+	}
+
+	for _, test := range tests {
+		if got := run(test.input, test.boxWidth); got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	w, dirs := parse(largeExample, 2)
+	start := w.String()
+
+	w.Seek(dirs, len(dirs))
+	end := w.String()
+
+	w.Seek(dirs, 0)
+	if got := w.String(); got != start {
+		t.Fatalf("undoing every move left\n%s\nwant\n%s", got, start)
+	}
+
+	w.Seek(dirs, len(dirs))
+	if got := w.String(); got != end {
+		t.Fatalf("redoing every move left\n%s\nwant\n%s", got, end)
+	}
+}
+
+// -- Linear-scan engine that Warehouse replaced, kept as a baseline.
+type linearWarehouse struct {
+	walls    []Coord
+	boxes    []Coord
+	robot    Coord
+	boxWidth int
+}
+
+func newLinearWarehouse(input string, boxWidth int) (w linearWarehouse, dirs []byte) {
+	s := bufio.NewScanner(strings.NewReader(input))
+	w.boxWidth = boxWidth
+
+	for row := 0; s.Scan(); row += 1 {
+		line := s.Text()
+		if len(line) == 0 {
+			break
+		}
+
+		for col, ch := range line {
+			pos := Coord{row, col * boxWidth}
+
+			switch ch {
+			case '#':
+				for offset := range boxWidth {
+					w.walls = append(w.walls, Coord{pos.Row, pos.Col + offset})
+				}
+			case 'O':
+				w.boxes = append(w.boxes, pos)
+			case '@':
+				w.robot = pos
+			}
+		}
+	}
+
+	return w, ParseMoves(s)
+}
+
+func (w *linearWarehouse) boxCovering(pos Coord) int {
+	return slices.IndexFunc(w.boxes, func(left Coord) bool {
+		return left.Row == pos.Row && left.Col <= pos.Col && pos.Col < left.Col+w.boxWidth
+	})
+}
+
+func (w *linearWarehouse) moveRobot(dir byte) {
+	next := w.robot.toward(dir)
+	if slices.Contains(w.walls, next) {
+		return
+	}
+
+	if first := w.boxCovering(next); first != -1 {
+		pushed := []int{first}
+
+		for i := 0; i < len(pushed); i += 1 {
+			left := w.boxes[pushed[i]]
+
+			for offset := range w.boxWidth {
+				cell := Coord{left.Row, left.Col + offset}.toward(dir)
+				if slices.Contains(w.walls, cell) {
+					return
+				}
+
+				other := w.boxCovering(cell)
+				if other != -1 && !slices.Contains(pushed, other) {
+					pushed = append(pushed, other)
+				}
+			}
+		}
+
+		for _, index := range pushed {
+			w.boxes[index] = w.boxes[index].toward(dir)
+		}
+	}
+
+	w.robot = next
+}
+
+func (w *linearWarehouse) score() (score int) {
+	for _, left := range w.boxes {
+		score += (left.Row * 100) + left.Col
+	}
+	return score
+}
+
+func randomInput(size int, numMoves int) string {
+	rng := rand.New(rand.NewPCG(15, 2024))
+
+	var sb strings.Builder
+	for row := range size {
+		for col := range size {
+			switch {
+			case row == size/2 && col == size/2:
+				sb.WriteByte('@')
+			case row == 0 || col == 0 || row == size-1 || col == size-1 || rng.Float64() < 0.06:
+				sb.WriteByte('#')
+			case rng.Float64() < 0.27:
+				sb.WriteByte('O')
+			default:
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteByte('\n')
+	for index := range numMoves {
+		sb.WriteByte("^v<>"[rng.IntN(4)])
+		if (index+1)%1000 == 0 {
+			sb.WriteByte('\n')
+		}
+	}
+	sb.WriteByte('\n')
+
+	return sb.String()
+}
+
+func TestMatchesLinear(t *testing.T) {
+	inputs := map[string]string{
+		"example": largeExample,
+		"random":  randomInput(30, 5000),
+	}
+
+	for name, input := range inputs {
+		for _, boxWidth := range []int{1, 2, 3} {
+			linear, dirs := newLinearWarehouse(input, boxWidth)
+			for _, dir := range dirs {
+				linear.moveRobot(dir)
+			}
+
+			if got, want := run(input, boxWidth), linear.score(); got != want {
+				t.Errorf("%s, width %d: grid scored %d, linear scored %d", name, boxWidth, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkEngines(b *testing.B) {
+	input := randomInput(50, 20000)
+
+	for _, boxWidth := range []int{1, 2} {
+		b.Run(fmt.Sprintf("linear/width=%d", boxWidth), func(b *testing.B) {
+			for range b.N {
+				w, dirs := newLinearWarehouse(input, boxWidth)
+				for _, dir := range dirs {
+					w.moveRobot(dir)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("grid/width=%d", boxWidth), func(b *testing.B) {
+			for range b.N {
+				run(input, boxWidth)
+			}
+		})
+	}
+}